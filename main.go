@@ -20,15 +20,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 
 	"github.com/bcoe/slsa-github-generator-node/builder/pkg"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
 )
 
 func usage(p string) {
 	panic(fmt.Sprintf(`Usage: 
-	 %s build [--dry] slsa-releaser.yml
-	 %s provenance --binary-name $NAME --digest $DIGEST --command $COMMAND --env $ENV`, p, p))
+	 %s build [--dry] [--result $FILE] [--sbom-dir $DIR] [--manifest-dir $DIR] slsa-releaser.yml [$ENVS]
+	 %s provenance [--binary-name $NAME --digest $DIGEST] [--checksums $FILE] --command $COMMAND --env $ENV [--materials $MATERIALS] [--builder-commit $SHA1] [--build-result $FILE] [--policy $POLICY] [--install-command $COMMAND] [--npmrc $NPMRC] [--event-redaction minimal|standard] [--predicate-version v0.2|v1]
+	 %s attest-sbom --build-result $FILE $SBOM...
+	 %s rebuild --provenance $FILE [--published $FILE]`, p, p, p, p))
 }
 
 func check(e error) {
@@ -41,13 +43,36 @@ func main() {
 	// Build command.
 	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
 	buildDry := buildCmd.Bool("dry", false, "dry run of the build without invoking compiler")
+	buildResult := buildCmd.String("result", "", "file to write the build result to")
+	buildSBOMDir := buildCmd.String("sbom-dir", "", "directory to write the CycloneDX and SPDX SBOMs of the package to")
+	buildManifestDir := buildCmd.String("manifest-dir", "", "directory to write the manifest of the files of the package to")
 
 	// Provenance command.
 	provenanceCmd := flag.NewFlagSet("provenance", flag.ExitOnError)
 	provenanceName := provenanceCmd.String("binary-name", "", "untrusted binary name of the artifact built")
-	provenanceDigest := provenanceCmd.String("digest", "", "sha256 digest of the untrusted binary")
+	provenanceDigest := provenanceCmd.String("digest", "", "digests of the untrusted binary, in hex or SRI format")
+	provenanceChecksums := provenanceCmd.String("checksums", "", "file containing the sha256 or sha512 checksums of the artifacts built")
 	provenanceCommand := provenanceCmd.String("command", "", "command used to compile the binary")
 	provenanceEnv := provenanceCmd.String("env", "", "env variables used to compile the binary")
+	provenanceMaterials := provenanceCmd.String("materials", "", "digests of the source files that define the build")
+	provenanceBuilderCommit := provenanceCmd.String("builder-commit", "", "sha1 of the commit of the builder repository")
+	provenanceBuildResult := provenanceCmd.String("build-result", "", "file containing the build result")
+	provenancePolicy := provenanceCmd.String("policy", "", "release policy, as output by the dry run of the build")
+	provenanceInstallCommand := provenanceCmd.String("install-command", "", "command used to install the dependencies before the build")
+	provenanceNpmrc := provenanceCmd.String("npmrc", "", "settings of the .npmrc file of the project, as output by the dry run of the build")
+	provenanceEventRedaction := provenanceCmd.String("event-redaction", pkg.RedactionMinimal,
+		fmt.Sprintf("redaction policy of the event payload: %s or %s", pkg.RedactionMinimal, pkg.RedactionStandard))
+	provenancePredicateVersion := provenanceCmd.String("predicate-version", pkg.PredicateVersionV02,
+		fmt.Sprintf("version of the SLSA provenance predicate: %s or %s", pkg.PredicateVersionV02, pkg.PredicateVersionV1))
+
+	// SBOM attestation command.
+	attestSBOMCmd := flag.NewFlagSet("attest-sbom", flag.ExitOnError)
+	attestSBOMBuildResult := attestSBOMCmd.String("build-result", "", "file containing the build result that recorded the SBOMs")
+
+	// Rebuild command.
+	rebuildCmd := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	rebuildProvenance := rebuildCmd.String("provenance", "", "file containing the provenance envelope of the package, whose signature is not verified")
+	rebuildPublished := rebuildCmd.String("published", "", "published tarball of the package, compared file by file if the rebuild differs")
 
 	// Expect a sub-command.
 	if len(os.Args) < 2 {
@@ -57,32 +82,49 @@ func main() {
 	switch os.Args[1] {
 	case buildCmd.Name():
 		buildCmd.Parse(os.Args[2:])
-		if len(buildCmd.Args()) < 1 {
+		args := buildCmd.Args()
+		if len(args) < 1 || len(args) > 2 {
 			usage(os.Args[0])
 		}
 
-		node, err := exec.LookPath("node")
+		// The env variables are optional.
+		envs := ""
+		if len(args) == 2 {
+			envs = args[1]
+		}
+
+		cfg, err := pkg.ConfigFromFile(args[0])
 		check(err)
 
-		npm, err := exec.LookPath("npm")
+		pkgJson, err := pkg.PkgJSONFromFile("package.json")
 		check(err)
+		fmt.Println(pkgJson)
 
-		cfg, err := pkg.PkgJSONFromFile("package.json")
+		toolchain, err := pkg.ResolveToolchain(pkgJson, ".", os.Getenv(pkg.SetupNodeVersionEnv))
 		check(err)
-		fmt.Println(cfg)
 
-		nodebuild := pkg.NodeBuildNew(node, npm, cfg)
+		nodebuild := pkg.NodeBuildNew(toolchain, cfg, pkgJson, args[0])
+		nodebuild.SetSBOMDir(*buildSBOMDir)
+		nodebuild.SetManifestDir(*buildManifestDir)
 
 		// Set env variables encoded as arguments.
-		err = nodebuild.SetArgEnvVariables(buildCmd.Args()[1])
+		err = nodebuild.SetArgEnvVariables(envs)
 		check(err)
 
-		err = nodebuild.Run(*buildDry)
+		result, err := nodebuild.Run(*buildDry)
 		check(err)
+
+		if result != nil && *buildResult != "" {
+			err = pkg.WriteBuildResult(*buildResult, result)
+			check(err)
+		}
 	case provenanceCmd.Name():
 		provenanceCmd.Parse(os.Args[2:])
 		// Note: *provenanceEnv may be empty.
-		if *provenanceName == "" || *provenanceDigest == "" ||
+		// Subjects are passed by name and digest, in a checksums
+		// file and/or in the build result.
+		if (*provenanceName == "") != (*provenanceDigest == "") ||
+			(*provenanceName == "" && *provenanceChecksums == "" && *provenanceBuildResult == "") ||
 			*provenanceCommand == "" {
 			usage(os.Args[0])
 		}
@@ -92,17 +134,96 @@ func main() {
 			panic(errors.New("environment variable GITHUB_CONTEXT not present"))
 		}
 
-		attBytes, err := pkg.GenerateProvenance(*provenanceName, *provenanceDigest,
-			githubContext, *provenanceCommand, *provenanceEnv)
+		var subjects []intoto.Subject
+		if *provenanceName != "" {
+			digests, err := pkg.DigestSetFromString(*provenanceDigest)
+			check(err)
+			subjects = append(subjects, pkg.NewSubject(*provenanceName, digests))
+		}
+
+		if *provenanceChecksums != "" {
+			s, err := pkg.SubjectsFromChecksumsFile(*provenanceChecksums)
+			check(err)
+			subjects = append(subjects, s...)
+		}
+
+		var result *pkg.BuildResult
+		if *provenanceBuildResult != "" {
+			var err error
+			result, err = pkg.BuildResultFromFile(*provenanceBuildResult)
+			check(err)
+		}
+
+		attBytes, err := pkg.GenerateProvenance(subjects, githubContext, *provenanceCommand, *provenanceEnv,
+			&pkg.ProvenanceOptions{
+				PredicateVersion: *provenancePredicateVersion,
+				Materials:        *provenanceMaterials,
+				BuilderCommit:    *provenanceBuilderCommit,
+				BuildResult:      result,
+				EventRedaction:   *provenanceEventRedaction,
+				Policy:           *provenancePolicy,
+				InstallCommand:   *provenanceInstallCommand,
+				Npmrc:            *provenanceNpmrc,
+			})
 		check(err)
 
-		filename := fmt.Sprintf("%s.intoto.jsonl", *provenanceName)
+		// A single statement covers all the subjects.
+		name := *provenanceName
+		if name == "" {
+			name = "attestation"
+		}
+		filename := fmt.Sprintf("%s.intoto.jsonl", name)
 		err = ioutil.WriteFile(filename, attBytes, 0600)
 		check(err)
 
 		fmt.Printf("::set-output name=signed-provenance-name::%s\n", filename)
+
+		// The SRI string of the package, from the digests of the
+		// subject recorded by the builder.
+		if result != nil {
+			if integrity := pkg.PackageIntegrity(result.Subjects, *provenanceName); integrity != "" {
+				fmt.Printf("::set-output name=node-package-integrity::%s\n", integrity)
+			}
+		}
+	case attestSBOMCmd.Name():
+		attestSBOMCmd.Parse(os.Args[2:])
+		if *attestSBOMBuildResult == "" || len(attestSBOMCmd.Args()) < 1 {
+			usage(os.Args[0])
+		}
+
+		result, err := pkg.BuildResultFromFile(*attestSBOMBuildResult)
+		check(err)
+
+		// Each SBOM is signed as its own attestation, written next to it.
+		for _, sbom := range attestSBOMCmd.Args() {
+			attBytes, err := pkg.GenerateSBOMAttestation(sbom, result)
+			check(err)
+
+			err = ioutil.WriteFile(sbom+".intoto.jsonl", attBytes, 0600)
+			check(err)
+		}
+	case rebuildCmd.Name():
+		rebuildCmd.Parse(os.Args[2:])
+		if *rebuildProvenance == "" {
+			usage(os.Args[0])
+		}
+
+		result, err := pkg.Rebuild(*rebuildProvenance, *rebuildPublished)
+		check(err)
+
+		fmt.Println("package", result.Package)
+		fmt.Println("expected", result.Expected)
+		fmt.Println("rebuilt", result.Digest)
+		if !result.Reproducible {
+			fmt.Println("the rebuilt package differs from the provenance")
+			for _, d := range result.Diff {
+				fmt.Println(d)
+			}
+			os.Exit(1)
+		}
+		fmt.Println("the rebuilt package matches the provenance")
 	default:
-		fmt.Println("expected 'build' or 'provenance' subcommands")
+		fmt.Println("expected 'build', 'provenance', 'attest-sbom' or 'rebuild' subcommands")
 		os.Exit(1)
 	}
 }
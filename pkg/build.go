@@ -20,8 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
-	"syscall"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
 )
 
 var (
@@ -45,38 +50,89 @@ var allowedEnvVariablePrefix = map[string]bool{
 }
 
 type NodeBuild struct {
-	pkgJson *PkgJsonConfig
-	node   string
-	npm    string
+	toolchain  *Toolchain
+	cfg        *GoReleaserConfig
+	pkgJson    *PkgJsonConfig
+	configPath string
+	// sbomDir is the directory the SBOMs are written to.
+	// No SBOM is written if it is empty.
+	sbomDir string
+	// manifestDir is the directory the manifest of the files
+	// of the tarball is written to. No manifest is written if
+	// it is empty.
+	manifestDir string
+	node        string
+	npm         string
 	// Note: static env variables are contained in cfg.Env.
 	argEnv map[string]string
 }
 
-func NodeBuildNew(node string, npm string, pkgJson *PkgJsonConfig) *NodeBuild {
+func NodeBuildNew(toolchain *Toolchain, cfg *GoReleaserConfig, pkgJson *PkgJsonConfig, configPath string) *NodeBuild {
 	c := NodeBuild{
-		pkgJson: pkgJson,
-		node:   node,
-		npm:    npm,
-		argEnv:  make(map[string]string),
+		toolchain:  toolchain,
+		cfg:        cfg,
+		pkgJson:    pkgJson,
+		configPath: configPath,
+		node:       toolchain.NodePath,
+		npm:        toolchain.NpmPath,
+		argEnv:     make(map[string]string),
 	}
 
 	return &c
 }
 
-func (b *NodeBuild) Run(dry bool) error {
+// Run runs the build. A dry run only prints the resolved outputs
+// and returns a nil result.
+func (b *NodeBuild) Run(dry bool) (*BuildResult, error) {
 	// Set flags.
 	flags, err := b.generateFlags()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Generate env variables.
 	envs, err := b.generateEnvVariables()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	com := append([]string{b.node, b.npm, "pack"}, flags...)
+	install := b.installCommand()
+
+	// Fail before the dependencies are installed, in the dry run.
+	if err := checkLockfile(b.pkgJson, "."); err != nil {
+		return nil, err
+	}
+
+	if err := b.evaluateTagVersion(); err != nil {
+		return nil, err
+	}
+
+	if err := b.evaluateDependencies(); err != nil {
+		return nil, err
+	}
+
+	scripts, err := b.auditInstallScripts()
+	if err != nil {
+		return nil, err
+	}
+
+	// npm reads the .npmrc file of the project.
+	npmrc, err := npmrcSettings(".")
+	if err != nil {
+		return nil, err
+	}
+
+	// The files npm pack will include, computed before the
+	// scripts of the build run.
+	pl, err := packlist(".", b.node, b.npm, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.verifyCheckout(pl, os.Getenv("GITHUB_SHA"), dry); err != nil {
+		return nil, err
+	}
 
 	// A dry run prints the information that is trusted, before
 	// the compiler is invoked.
@@ -84,36 +140,311 @@ func (b *NodeBuild) Run(dry bool) error {
 		// Generate filename.
 		filename, err := b.generateOutputFilename()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Share the resolved name of the binary.
 		fmt.Printf("::set-output name=node-package-name::%s\n", filename)
 		command, err := marshallList(com)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// Share the command used.
 		fmt.Printf("::set-output name=node-command::%s\n", command)
 
+		// Share the version of node, which the build installs again.
+		fmt.Printf("::set-output name=node-version::%s\n", b.toolchain.NodeVersion)
+
+		minstall, err := marshallList(install)
+		if err != nil {
+			return nil, err
+		}
+		// Share the command that installs the dependencies.
+		fmt.Printf("::set-output name=node-install-command::%s\n", minstall)
+
 		env, err := b.generateCommandEnvVariables()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		menv, err := marshallList(env)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Share the env variables used.
 		fmt.Printf("::set-output name=node-env::%s\n", menv)
-		return nil
+
+		files := append([]string{b.configPath}, sourceMaterialFiles...)
+		digests, err := sourceDigests(files)
+		if err != nil {
+			return nil, err
+		}
+
+		mdigests, err := marshallMap(digests)
+		if err != nil {
+			return nil, err
+		}
+
+		// Share the digests of the files that define the build.
+		fmt.Printf("::set-output name=node-materials::%s\n", mdigests)
+
+		mpolicy, err := marshallPolicy(b.releasePolicy())
+		if err != nil {
+			return nil, err
+		}
+
+		// Share the release policy, evaluated before signing the provenance.
+		fmt.Printf("::set-output name=node-policy::%s\n", mpolicy)
+
+		mnpmrc, err := marshallMap(npmrc)
+		if err != nil {
+			return nil, err
+		}
+
+		// Share the settings of .npmrc.
+		fmt.Printf("::set-output name=node-npmrc::%s\n", mnpmrc)
+
+		mpacklist, err := marshallPacklist(pl)
+		if err != nil {
+			return nil, err
+		}
+
+		// Share the files the tarball will contain.
+		fmt.Printf("::set-output name=node-packlist::%s\n", mpacklist)
+		return nil, nil
 	}
 
+	fmt.Println("install command", install)
 	fmt.Println("command", com)
 	fmt.Println("env", envs)
-	return syscall.Exec(b.node, com, envs)
+	fmt.Println("npmrc", npmrc)
+	fmt.Printf("packlist %d files, %d bytes\n", len(pl.Files), pl.Size)
+
+	// The timestamps are recorded by the builder rather than
+	// reported by the build itself.
+	startedOn := time.Now().UTC()
+
+	// The cache is emptied so that all the packages are
+	// downloaded and verified against the lockfile.
+	if err := os.RemoveAll(npmCacheDir()); err != nil {
+		return nil, fmt.Errorf("os.RemoveAll: %w", err)
+	}
+
+	commands := [][]string{install, com}
+	result := &InstallResult{
+		Offline: b.cfg.Install.Offline,
+	}
+	if result.Offline {
+		var paths []string
+		result.Tarballs, paths, err = b.prepareOfflineInstall()
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) > 0 {
+			result.CacheCommand = b.cacheAddCommand(paths)
+			commands = append([][]string{result.CacheCommand}, commands...)
+		}
+	}
+
+	for _, c := range commands {
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Env = envs
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.Join(c[1:3], " "), err)
+		}
+	}
+
+	finishedOn := time.Now().UTC()
+
+	filename, err := b.generateOutputFilename()
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify what npm pack put in the tarball,
+	// before the builder reads it to normalize it.
+	manifest, err := inspectTarball(filename, &b.cfg.Contents)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := &PackResult{
+		Normalized: b.cfg.Pack.Normalize,
+	}
+	if pack.Normalized {
+		pack.GoVersion = runtime.Version()
+		if err := normalizeTarball(filename, &b.cfg.Contents); err != nil {
+			return nil, err
+		}
+		// The modes of the files are normalized.
+		if manifest, err = inspectTarball(filename, &b.cfg.Contents); err != nil {
+			return nil, err
+		}
+	}
+
+	digests, err := fileDigests(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pl.verify(manifest, b.cfg.Contents.Generated); err != nil {
+		return nil, err
+	}
+
+	var manifestSubject *intoto.Subject
+	if b.manifestDir != "" {
+		s, err := WriteManifest(b.manifestDir, manifest)
+		if err != nil {
+			return nil, err
+		}
+		manifestSubject = &s
+	}
+
+	dependencies, err := dependencyMaterials(b.pkgJson, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var sboms []intoto.Subject
+	if b.sbomDir != "" {
+		sboms, err = WriteSBOMs(b.sbomDir, ".", filename, b.pkgJson, digests, finishedOn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &BuildResult{
+		Version:        buildResultVersion,
+		Subjects:       []intoto.Subject{NewSubject(filename, digests)},
+		StartedOn:      &startedOn,
+		FinishedOn:     &finishedOn,
+		Environment:    runnerEnvironment(b.toolchain),
+		Toolchain:      b.toolchain,
+		Dependencies:   dependencies,
+		InstallScripts: scripts,
+		Install:        result,
+		Pack:           pack,
+		SBOMs:          sboms,
+		Manifest:       manifestSubject,
+	}, nil
+}
+
+// releasePolicy returns the release policy of the config,
+// with the name and version of the package.
+func (b *NodeBuild) releasePolicy() *ReleasePolicy {
+	policy := b.cfg.Policy
+	policy.PackageName = b.pkgJson.Name
+	policy.PackageVersion = b.pkgJson.Version
+	return &policy
+}
+
+// evaluateTagVersion verifies that the tag the build runs for matches
+// the version of the package, so that a mismatch fails before the
+// package is packed. The whole policy is evaluated again before the
+// provenance is signed.
+func (b *NodeBuild) evaluateTagVersion() error {
+	refType := os.Getenv("GITHUB_REF_TYPE")
+	if refType != "tag" {
+		return nil
+	}
+
+	policy := b.releasePolicy()
+	if !policy.checksTagVersion(refType) {
+		return nil
+	}
+	release, err := policy.evaluateTagVersion(os.Getenv("GITHUB_REF"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("tag %s releases %s@%s\n", release.Tag, release.Package, release.Version)
+	return nil
+}
+
+// verifyCheckout verifies that the working tree is the commit sha
+// the run was triggered for, unmodified, so that the package is
+// built from the source recorded in the provenance. The builder
+// itself may be downloaded to the working tree. Only a dry run,
+// which builds nothing, may run without knowing the commit.
+func (b *NodeBuild) verifyCheckout(pl *Packlist, sha string, dry bool) error {
+	if sha == "" {
+		if dry {
+			fmt.Println("warning: GITHUB_SHA is not set, the checkout is not verified")
+			return nil
+		}
+		return fmt.Errorf("%w: GITHUB_SHA is not set", errorUnknownCommit)
+	}
+
+	exempt := make(map[string]bool)
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("os.Getwd: %w", err)
+	}
+	if exe, err := os.Executable(); err == nil {
+		if rel, err := filepath.Rel(wd, exe); err == nil && !strings.HasPrefix(rel, "..") {
+			exempt[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	return verifyCheckout(".", sha, pl.Files, exempt)
+}
+
+// evaluateDependencies verifies that the packages of the lockfile
+// are pinned, and resolved from the registries allowed by the config.
+func (b *NodeBuild) evaluateDependencies() error {
+	l, err := lockfileFromDir(".")
+	if err != nil || l == nil {
+		return err
+	}
+	if err := evaluatePins(l, &b.cfg.Registries); err != nil {
+		return err
+	}
+	return evaluateRegistries(l, &b.cfg.Registries)
+}
+
+// auditInstallScripts lists the dependencies with install scripts, and
+// fails if one of them is not allowed by the config. The packages are
+// nil if the lockfile does not record install scripts.
+func (b *NodeBuild) auditInstallScripts() (*InstallScripts, error) {
+	scripts := &InstallScripts{
+		IgnoreScripts: !b.cfg.InstallScripts.Run,
+	}
+
+	l, err := lockfileFromDir(".")
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || !l.installScripts {
+		fmt.Println("warning: the lockfile does not record install scripts, they are not audited")
+		return scripts, nil
+	}
+
+	scripts.Packages, err = auditInstallScripts(l, &b.cfg.InstallScripts)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("install scripts", scripts.Packages)
+	return scripts, nil
+}
+
+// prepareOfflineInstall downloads and verifies the tarballs
+// of the packages of the lockfile, for an offline install.
+func (b *NodeBuild) prepareOfflineInstall() ([]ResourceDescriptor, []string, error) {
+	l, err := lockfileFromDir(".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tarballs, paths, err := buildTarballCache(l, tarballCacheDir(), httpFetch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Println("offline install from", len(tarballs), "tarballs")
+	return tarballs, paths, nil
 }
 
 func marshallList(args []string) (string, error) {
@@ -129,6 +460,15 @@ func marshallList(args []string) (string, error) {
 	return encoded, nil
 }
 
+func marshallMap(m map[string]string) (string, error) {
+	jsonData, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(jsonData), nil
+}
+
 func (b *NodeBuild) generateCommandEnvVariables() ([]string, error) {
 	var env []string
 
@@ -164,6 +504,17 @@ func (b *NodeBuild) generateEnvVariables() ([]string, error) {
 	return env, nil
 }
 
+// SetSBOMDir sets the directory the SBOMs of the package are written to.
+func (b *NodeBuild) SetSBOMDir(dir string) {
+	b.sbomDir = dir
+}
+
+// SetManifestDir sets the directory the manifest of
+// the files of the tarball is written to.
+func (b *NodeBuild) SetManifestDir(dir string) {
+	b.manifestDir = dir
+}
+
 func (b *NodeBuild) SetArgEnvVariables(envs string) error {
 	// Notes:
 	// - I've tried running the re-usable workflow in a step
@@ -196,7 +547,17 @@ func (b *NodeBuild) SetArgEnvVariables(envs string) error {
 func (b *NodeBuild) generateOutputFilename() (string, error) {
 	// TODO: validate that "name", "version", are not nil.
 
-	return b.pkgJson.Name + "-" + b.pkgJson.Version + ".tgz", nil
+	return packageFilename(b.pkgJson), nil
+}
+
+// packageFilename returns the name of the tarball npm pack writes.
+// Like npm, the name of a scoped package has its "@" removed and
+// its "/" replaced with "-", e.g., @scope/name-1.0.0.tgz is written
+// as scope-name-1.0.0.tgz.
+func packageFilename(pkgJson *PkgJsonConfig) string {
+	name := strings.TrimPrefix(pkgJson.Name, "@")
+	name = strings.Replace(name, "/", "-", 1)
+	return name + "-" + pkgJson.Version + ".tgz"
 }
 
 func (b *NodeBuild) generateFlags() ([]string, error) {
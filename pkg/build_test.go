@@ -185,6 +185,17 @@ func Test_generateOutputFilename(t *testing.T) {
 				fn:  "foo-pkg-1.2.3.tgz",
 			},
 		},
+		{
+			name:    "@my-org/foo-pkg",
+			version: "1.2.3",
+			expected: struct {
+				err error
+				fn  string
+			}{
+				err: nil,
+				fn:  "my-org-foo-pkg-1.2.3.tgz",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -200,7 +211,7 @@ func Test_generateOutputFilename(t *testing.T) {
 			if err != nil {
 				t.Errorf("pkgJSONFromConfig: %v", err)
 			}
-			b := NodeBuildNew("node compiler", "npm", c)
+			b := NodeBuildNew(&Toolchain{NodePath: "node compiler", NpmPath: "npm"}, &GoReleaserConfig{}, c, "")
 
 			fn, err := b.generateOutputFilename()
 			if !errCmp(err, tt.expected.err) {
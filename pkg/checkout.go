@@ -0,0 +1,125 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+var (
+	errorUnexpectedCommit = errors.New("checked out commit does not match the run")
+	errorDirtyCheckout    = errors.New("working tree does not match the commit")
+	errorUnknownCommit    = errors.New("commit of the run is unknown")
+)
+
+// verifyCheckout verifies that the working tree in dir is the commit
+// expected, unmodified, as reported by git status: HEAD is the commit,
+// no file is staged or modified, and no file is untracked unless it is
+// ignored by .gitignore. Ignored files are allowed, e.g. node_modules,
+// except the ones npm packs, which must be tracked. Paths in exempt are
+// not required to be tracked.
+func verifyCheckout(dir, expected string, packed []string, exempt map[string]bool) error {
+	head, err := runGitCommand(dir, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return err
+	}
+	head = strings.TrimSpace(head)
+	if head != expected {
+		return fmt.Errorf("%w: HEAD is %s, expected %s", errorUnexpectedCommit, head, expected)
+	}
+
+	// git status applies the attributes and filters of the files, e.g.
+	// line endings or Git LFS, and skips the entries of the index
+	// outside of a sparse checkout.
+	status, err := runGitCommand(dir, "status", "--porcelain", "-z", "--no-renames", "--untracked-files=all")
+	if err != nil {
+		return err
+	}
+	var staged, modified, untracked []string
+	isUntracked := make(map[string]bool)
+	for _, entry := range strings.Split(status, "\x00") {
+		// XY <path>, where X is the status of the index
+		// and Y the status of the working tree.
+		if len(entry) < 4 {
+			continue
+		}
+		x, y, p := entry[0], entry[1], entry[3:]
+		switch {
+		case x == '?':
+			isUntracked[p] = true
+			if !exempt[p] {
+				untracked = append(untracked, p)
+			}
+		default:
+			if x != ' ' {
+				staged = append(staged, p)
+			}
+			if y != ' ' {
+				modified = append(modified, p)
+			}
+		}
+	}
+
+	tracked, err := runGitCommand(dir, "ls-files", "-z")
+	if err != nil {
+		return err
+	}
+	index := make(map[string]bool)
+	for _, p := range strings.Split(tracked, "\x00") {
+		index[p] = true
+	}
+	for _, p := range packed {
+		if !index[p] && !exempt[p] && !isUntracked[p] {
+			untracked = append(untracked, p)
+		}
+	}
+
+	var diffs []string
+	for _, d := range []struct {
+		name  string
+		paths []string
+	}{
+		{"staged", staged},
+		{"modified", modified},
+		{"untracked", untracked},
+	} {
+		if len(d.paths) > 0 {
+			sort.Strings(d.paths)
+			diffs = append(diffs, d.name+" "+strings.Join(d.paths, ", "))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%w: %s", errorDirtyCheckout, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// runGitCommand runs git with args in dir and returns its output.
+func runGitCommand(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,278 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// runGit runs git in dir. Tests are skipped if git is not installed.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir,
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writeTestRepository commits a project to a new repository in
+// a temporary directory, and returns the directory and the commit.
+func writeTestRepository(t *testing.T) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	// A large file, changed between two commits, is stored
+	// as a delta once the objects are packed.
+	lines := make([]string, 2000)
+	for i := range lines {
+		lines[i] = "module.exports.value = 'constant value';"
+	}
+	writeTestTree(t, dir, map[string]string{
+		"package.json": `{"name":"test","version":"1.0.1"}`,
+		"lib/index.js": strings.Join(lines, "\n"),
+		".gitignore":   "node_modules\n",
+	})
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "first")
+
+	lines[1000] = "module.exports.other = 'changed value';"
+	writeTestTree(t, dir, map[string]string{
+		"lib/index.js": strings.Join(lines, "\n"),
+		"bin/cli.sh":   "#!/bin/sh\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "bin", "cli.sh"), 0o755); err != nil {
+		t.Fatalf("os.Chmod: %v", err)
+	}
+	if err := os.Symlink("lib/index.js", filepath.Join(dir, "main.js")); err != nil {
+		t.Fatalf("os.Symlink: %v", err)
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "second")
+
+	return dir, runGit(t, dir, "rev-parse", "HEAD")
+}
+
+func Test_verifyCheckout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, dir string)
+		commit   string
+		packed   []string
+		exempt   map[string]bool
+		expected error
+	}{
+		{
+			name: "clean",
+		},
+		{
+			name: "packed objects and refs",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "gc", "-q", "--aggressive")
+				runGit(t, dir, "pack-refs", "--all")
+				if _, err := os.Stat(filepath.Join(dir, ".git", "objects", "pack")); err != nil {
+					t.Fatalf("os.Stat: %v", err)
+				}
+			},
+		},
+		{
+			name: "detached HEAD",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "checkout", "-q", "--detach")
+			},
+		},
+		{
+			name: "index version 4",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "update-index", "--index-version", "4")
+			},
+		},
+		{
+			name:     "other commit",
+			commit:   "0123456789abcdef0123456789abcdef01234567",
+			expected: errorUnexpectedCommit,
+		},
+		{
+			name: "modified file",
+			setup: func(t *testing.T, dir string) {
+				writeTestTree(t, dir, map[string]string{"package.json": `{"name":"test","version":"1.0.2"}`})
+			},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name: "executable bit",
+			setup: func(t *testing.T, dir string) {
+				if err := os.Chmod(filepath.Join(dir, "package.json"), 0o755); err != nil {
+					t.Fatalf("os.Chmod: %v", err)
+				}
+			},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name: "deleted file",
+			setup: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "main.js")); err != nil {
+					t.Fatalf("os.Remove: %v", err)
+				}
+			},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name: "staged file",
+			setup: func(t *testing.T, dir string) {
+				writeTestTree(t, dir, map[string]string{"lib/extra.js": "x"})
+				runGit(t, dir, "add", "lib/extra.js")
+			},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name:     "untracked packed file",
+			packed:   []string{"package.json", "lib/extra.js"},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name: "untracked file",
+			setup: func(t *testing.T, dir string) {
+				writeTestTree(t, dir, map[string]string{".npmrc": "ignore-scripts=false\n"})
+			},
+			expected: errorDirtyCheckout,
+		},
+		{
+			name: "line endings converted on checkout",
+			setup: func(t *testing.T, dir string) {
+				writeTestTree(t, dir, map[string]string{".git/info/attributes": "*.js text eol=crlf\n"})
+				if err := os.Remove(filepath.Join(dir, "lib", "index.js")); err != nil {
+					t.Fatalf("os.Remove: %v", err)
+				}
+				runGit(t, dir, "checkout", "-q", "--", "lib/index.js")
+				b, err := os.ReadFile(filepath.Join(dir, "lib", "index.js"))
+				if err != nil {
+					t.Fatalf("os.ReadFile: %v", err)
+				}
+				if !strings.Contains(string(b), "\r\n") {
+					t.Fatal("lib/index.js was not converted")
+				}
+			},
+		},
+		{
+			name: "skip-worktree file",
+			setup: func(t *testing.T, dir string) {
+				runGit(t, dir, "update-index", "--skip-worktree", "package.json")
+				if err := os.Remove(filepath.Join(dir, "package.json")); err != nil {
+					t.Fatalf("os.Remove: %v", err)
+				}
+			},
+		},
+		{
+			name:   "untracked exempt file",
+			packed: []string{"package.json", "builder"},
+			exempt: map[string]bool{"builder": true},
+		},
+		{
+			name: "untracked ignored file",
+			setup: func(t *testing.T, dir string) {
+				writeTestTree(t, dir, map[string]string{"node_modules/a/index.js": "x"})
+			},
+			packed: []string{"package.json"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, commit := writeTestRepository(t)
+			if tt.setup != nil {
+				tt.setup(t, dir)
+			}
+			if tt.commit != "" {
+				commit = tt.commit
+			}
+
+			err := verifyCheckout(dir, commit, tt.packed, tt.exempt)
+			if !errCmp(err, tt.expected) {
+				t.Error(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_verifyCheckout_report(t *testing.T) {
+	t.Parallel()
+
+	dir, commit := writeTestRepository(t)
+	writeTestTree(t, dir, map[string]string{
+		"lib/index.js": "changed",
+		"lib/extra.js": "x",
+	})
+	runGit(t, dir, "add", "lib/extra.js")
+
+	err := verifyCheckout(dir, commit, []string{"lib/index.js", "notes.txt"}, nil)
+	expected := errorDirtyCheckout.Error() + ": staged lib/extra.js; modified lib/index.js; untracked notes.txt"
+	if err == nil || err.Error() != expected {
+		t.Errorf("got %v, expected %s", err, expected)
+	}
+}
+
+func Test_NodeBuild_verifyCheckout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dry      bool
+		expected error
+	}{
+		{
+			name:     "commit unknown",
+			expected: errorUnknownCommit,
+		},
+		{
+			name: "commit unknown in dry run",
+			dry:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := &NodeBuild{}
+			err := b.verifyCheckout(&Packlist{}, "", tt.dry)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -28,6 +29,7 @@ import (
 var (
 	errorInvalidEnvironmentVariable = errors.New("invalid environment variable")
 	errorUnsupportedVersion         = errors.New("version not supported")
+	errorInvalidPackageJSON         = errors.New("invalid package.json")
 )
 
 var supportedVersions = map[int]bool{
@@ -40,27 +42,61 @@ type goReleaserConfigFile struct {
 	Env     []string `yaml:"env"`
 	Flags   []string `yaml:"flags"`
 	Ldflags []string `yaml:"ldflags"`
-	Binary  string   `yaml:"binary`
+	Binary  string   `yaml:"binary"`
 	Version int      `yaml:"version"`
+	// Policy is optional: see ReleasePolicy.
+	Policy ReleasePolicy `yaml:"policy"`
+	// InstallScripts is optional: see InstallScriptPolicy.
+	InstallScripts InstallScriptPolicy `yaml:"install-scripts"`
+	// Install is optional: see InstallConfig.
+	Install InstallConfig `yaml:"install"`
+	// Registries is optional: see RegistryPolicy.
+	Registries RegistryPolicy `yaml:"registries"`
+	// Contents is optional: see ContentPolicy.
+	Contents ContentPolicy `yaml:"contents"`
+	// Pack is optional: see PackConfig.
+	Pack PackConfig `yaml:"pack"`
 }
 
 type GoReleaserConfig struct {
-	Goos    string
-	Goarch  string
-	Env     map[string]string
-	Flags   []string
-	Ldflags []string
-	Binary  string
+	Goos           string
+	Goarch         string
+	Env            map[string]string
+	Flags          []string
+	Ldflags        []string
+	Binary         string
+	Policy         ReleasePolicy
+	InstallScripts InstallScriptPolicy
+	Install        InstallConfig
+	Registries     RegistryPolicy
+	Contents       ContentPolicy
+	Pack           PackConfig
 }
 
 type PkgJsonConfig struct {
 	Name    string
 	Version string
+	Engines map[string]string
+	// BundleDependencies are the names of the dependencies
+	// included in the tarball.
+	BundleDependencies   []string
+	Dependencies         map[string]string
+	DevDependencies      map[string]string
+	OptionalDependencies map[string]string
 }
 
 type pkgJsonConfigFile struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Engines      map[string]string `json:"engines"`
+	Dependencies map[string]string `json:"dependencies"`
+	// Dependencies only installed to build the package.
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	// Either a list of names or true to bundle all the dependencies.
+	// npm also accepts the bundledDependencies spelling.
+	BundleDependencies  json.RawMessage `json:"bundleDependencies"`
+	BundledDependencies json.RawMessage `json:"bundledDependencies"`
 }
 
 func configFromString(b []byte) (*GoReleaserConfig, error) {
@@ -87,11 +123,33 @@ func fromConfig(cf *goReleaserConfigFile) (*GoReleaserConfig, error) {
 	}
 
 	cfg := GoReleaserConfig{
-		Goos:    cf.Goos,
-		Goarch:  cf.Goarch,
-		Flags:   cf.Flags,
-		Ldflags: cf.Ldflags,
-		Binary:  cf.Binary,
+		Goos:           cf.Goos,
+		Goarch:         cf.Goarch,
+		Flags:          cf.Flags,
+		Ldflags:        cf.Ldflags,
+		Binary:         cf.Binary,
+		Policy:         cf.Policy,
+		InstallScripts: cf.InstallScripts,
+		Install:        cf.Install,
+		Registries:     cf.Registries,
+		Contents:       cf.Contents,
+		Pack:           cf.Pack,
+	}
+
+	if err := cfg.Policy.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.InstallScripts.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Registries.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Contents.validate(); err != nil {
+		return nil, err
 	}
 
 	if err := cfg.setEnvs(cf); err != nil {
@@ -121,13 +179,48 @@ func PkgJSONFromFile(pathfn string) (*PkgJsonConfig, error) {
 
 func pkgJSONFromConfig(cf *pkgJsonConfigFile) (*PkgJsonConfig, error) {
 	cfg := PkgJsonConfig{
-		Name:    cf.Name,
-		Version: cf.Version,
+		Name:                 cf.Name,
+		Version:              cf.Version,
+		Engines:              cf.Engines,
+		Dependencies:         cf.Dependencies,
+		DevDependencies:      cf.DevDependencies,
+		OptionalDependencies: cf.OptionalDependencies,
+	}
+
+	bundle := cf.BundleDependencies
+	if len(bundle) == 0 {
+		bundle = cf.BundledDependencies
+	}
+	if err := cfg.setBundleDependencies(bundle, cf.Dependencies); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
 
+func (c *PkgJsonConfig) setBundleDependencies(raw json.RawMessage, deps map[string]string) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var all bool
+	if err := json.Unmarshal(raw, &all); err == nil {
+		if !all {
+			return nil
+		}
+		for name := range deps {
+			c.BundleDependencies = append(c.BundleDependencies, name)
+		}
+		sort.Strings(c.BundleDependencies)
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, &c.BundleDependencies); err != nil {
+		return fmt.Errorf("%w: bundleDependencies: %v", errorInvalidPackageJSON, err)
+	}
+	return nil
+}
+
 func validateVersion(cf *goReleaserConfigFile) error {
 	_, exists := supportedVersions[cf.Version]
 	if !exists {
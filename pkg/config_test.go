@@ -53,6 +53,51 @@ func Test_ConfigFromFile(t *testing.T) {
 			path:     "./testdata/releaser-invalid-envs.yml",
 			expected: errorInvalidEnvironmentVariable,
 		},
+		{
+			name:     "valid policy",
+			path:     "./testdata/releaser-valid-policy.yml",
+			expected: nil,
+		},
+		{
+			name:     "invalid policy",
+			path:     "./testdata/releaser-invalid-policy.yml",
+			expected: errorInvalidPolicy,
+		},
+		{
+			name:     "valid install scripts",
+			path:     "./testdata/releaser-valid-install-scripts.yml",
+			expected: nil,
+		},
+		{
+			name:     "invalid install scripts",
+			path:     "./testdata/releaser-invalid-install-scripts.yml",
+			expected: errorInvalidInstallScriptPolicy,
+		},
+		{
+			name:     "valid registries",
+			path:     "./testdata/releaser-valid-registries.yml",
+			expected: nil,
+		},
+		{
+			name:     "invalid registries",
+			path:     "./testdata/releaser-invalid-registries.yml",
+			expected: errorInvalidRegistryPolicy,
+		},
+		{
+			name:     "valid contents",
+			path:     "./testdata/releaser-valid-contents.yml",
+			expected: nil,
+		},
+		{
+			name:     "valid pack",
+			path:     "./testdata/releaser-valid-pack.yml",
+			expected: nil,
+		},
+		{
+			name:     "invalid contents",
+			path:     "./testdata/releaser-invalid-contents.yml",
+			expected: errorInvalidContentPolicy,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Re-initializing variable so it is not changed while executing the closure below
@@ -93,3 +138,60 @@ func Test_PkgJsonFromFile(t *testing.T) {
 		})
 	}
 }
+
+func Test_pkgJSONFromString_bundleDependencies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		pkgJSON  string
+		bundle   []string
+		expected error
+	}{
+		{
+			name:    "no bundle",
+			pkgJSON: `{"name": "a", "dependencies": {"b": "^1.0.0"}}`,
+		},
+		{
+			name:    "list",
+			pkgJSON: `{"name": "a", "dependencies": {"b": "^1.0.0", "c": "^1.0.0"}, "bundleDependencies": ["c"]}`,
+			bundle:  []string{"c"},
+		},
+		{
+			name:    "bundledDependencies spelling",
+			pkgJSON: `{"name": "a", "dependencies": {"b": "^1.0.0", "c": "^1.0.0"}, "bundledDependencies": ["b"]}`,
+			bundle:  []string{"b"},
+		},
+		{
+			name:    "all dependencies",
+			pkgJSON: `{"name": "a", "dependencies": {"c": "^1.0.0", "b": "^1.0.0"}, "bundleDependencies": true}`,
+			bundle:  []string{"b", "c"},
+		},
+		{
+			name:    "disabled",
+			pkgJSON: `{"name": "a", "dependencies": {"b": "^1.0.0"}, "bundleDependencies": false}`,
+		},
+		{
+			name:     "invalid",
+			pkgJSON:  `{"name": "a", "bundleDependencies": "b"}`,
+			expected: errorInvalidPackageJSON,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg, err := pkgJSONFromString([]byte(tt.pkgJSON))
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.bundle, cfg.BundleDependencies); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorInvalidContentPolicy = errors.New("invalid content policy")
+	errorInvalidTarball       = errors.New("invalid tarball")
+	errorForbiddenFile        = errors.New("file not allowed in the tarball")
+	errorTarballTooLarge      = errors.New("tarball too large")
+)
+
+const (
+	// defaultMaxFileSize is the default maximum size of a file of the tarball.
+	defaultMaxFileSize int64 = 64 << 20
+	// defaultMaxSize is the default maximum size of the files of the tarball.
+	defaultMaxSize int64 = 256 << 20
+
+	// The root directory of the files in the tarballs of npm.
+	tarballRoot = "package"
+
+	manifestExt = ".manifest.json"
+)
+
+// defaultDenyPatterns are files that are not meant to be published:
+// environment files, private keys and repositories.
+var defaultDenyPatterns = []string{
+	".env*",
+	"*.pem",
+	"*.key",
+	"id_rsa*",
+	".npmrc",
+	".git/",
+}
+
+// ContentPolicy restricts the files of the tarball. It is
+// defined in the releaser config.
+type ContentPolicy struct {
+	// Deny are patterns of files not allowed in the tarball, in
+	// addition to defaultDenyPatterns. Patterns without a slash, e.g.
+	// *.pem, match the name of a file or directory at any depth.
+	// Patterns ending with a slash, e.g. .git/, only match directories.
+	// Other patterns, e.g. test/fixtures/*, match the path of a file
+	// relative to the root of the package. They use the syntax of
+	// path.Match.
+	Deny []string `yaml:"deny"`
+	// Allow are patterns of files allowed even if they match a deny pattern.
+	Allow []string `yaml:"allow"`
+	// MaxFileSize is the maximum size of a file, in bytes.
+	// Defaults to defaultMaxFileSize.
+	MaxFileSize int64 `yaml:"max-file-size"`
+	// MaxSize is the maximum size of all the files, in bytes.
+	// Defaults to defaultMaxSize.
+	MaxSize int64 `yaml:"max-size"`
+	// Generated are patterns of files created by the scripts npm
+	// pack runs, e.g. prepack. They are not compared with the
+	// packed file list previewed by the dry run.
+	Generated []string `yaml:"generated"`
+}
+
+// ManifestFile is a file of the tarball.
+type ManifestFile struct {
+	// Path is relative to the root of the package.
+	Path   string         `json:"path"`
+	Size   int64          `json:"size"`
+	Mode   string         `json:"mode"`
+	Digest slsa.DigestSet `json:"digest,omitempty"`
+	// Link is the target of a symbolic link.
+	Link string `json:"link,omitempty"`
+}
+
+// Manifest lists the files of the tarball.
+type Manifest struct {
+	Package string         `json:"package"`
+	Files   []ManifestFile `json:"files"`
+}
+
+func (p *ContentPolicy) validate() error {
+	for _, patterns := range [][]string{p.Deny, p.Allow, p.Generated} {
+		for _, pattern := range patterns {
+			if strings.TrimSuffix(pattern, "/") == "" {
+				return fmt.Errorf("%w: empty pattern", errorInvalidContentPolicy)
+			}
+			if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+				return fmt.Errorf("%w: pattern %q: %v", errorInvalidContentPolicy, pattern, err)
+			}
+		}
+	}
+	if p.MaxFileSize < 0 || p.MaxSize < 0 {
+		return fmt.Errorf("%w: negative size limit", errorInvalidContentPolicy)
+	}
+	return nil
+}
+
+func (p *ContentPolicy) maxFileSize() int64 {
+	if p.MaxFileSize == 0 {
+		return defaultMaxFileSize
+	}
+	return p.MaxFileSize
+}
+
+func (p *ContentPolicy) maxSize() int64 {
+	if p.MaxSize == 0 {
+		return defaultMaxSize
+	}
+	return p.MaxSize
+}
+
+// denies returns the pattern that denies the file or directory
+// at path rel, relative to the root of the package.
+func (p *ContentPolicy) denies(rel string, dir bool) (string, bool) {
+	for _, pattern := range p.Allow {
+		if matchContentPattern(pattern, rel, dir) {
+			return "", false
+		}
+	}
+	for _, patterns := range [][]string{defaultDenyPatterns, p.Deny} {
+		for _, pattern := range patterns {
+			if matchContentPattern(pattern, rel, dir) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchContentPattern returns true if pattern matches
+// the file or directory at path rel; see ContentPolicy.
+func matchContentPattern(pattern, rel string, dir bool) bool {
+	components := strings.Split(rel, "/")
+	dirs := components
+	if !dir {
+		dirs = components[:len(components)-1]
+	}
+
+	switch {
+	case strings.HasSuffix(pattern, "/"):
+		pattern = strings.TrimSuffix(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			for i := range dirs {
+				if ok, _ := path.Match(pattern, strings.Join(dirs[:i+1], "/")); ok {
+					return true
+				}
+			}
+			return false
+		}
+		for _, c := range dirs {
+			if ok, _ := path.Match(pattern, c); ok {
+				return true
+			}
+		}
+		return false
+	case strings.Contains(pattern, "/"):
+		for i := range components {
+			if ok, _ := path.Match(pattern, strings.Join(components[:i+1], "/")); ok {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, c := range components {
+			if ok, _ := path.Match(pattern, c); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// inspectTarball verifies the entries of the tarball of the package
+// against the policy, and returns the manifest of its files. Every
+// entry must be under the package/ directory, and links must not point
+// outside of it. Device files are not allowed.
+func inspectTarball(fn string, policy *ContentPolicy) (*Manifest, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: gzip.NewReader: %v", errorInvalidTarball, err)
+	}
+	defer gz.Close()
+
+	m := &Manifest{
+		Package: filepath.Base(fn),
+		Files:   []ManifestFile{},
+	}
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errorInvalidTarball, err)
+		}
+
+		rel, err := tarballPath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := hdr.Typeflag == tar.TypeDir
+		if rel == "" {
+			if dir {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s is not a directory", errorInvalidTarball, hdr.Name)
+		}
+		if pattern, denied := policy.denies(rel, dir); denied {
+			return nil, fmt.Errorf("%w: %s matches %q", errorForbiddenFile, rel, pattern)
+		}
+
+		file := ManifestFile{
+			Path: rel,
+			Size: hdr.Size,
+			Mode: fmt.Sprintf("%04o", hdr.Mode&0o7777),
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+			if hdr.Size > policy.maxFileSize() {
+				return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", errorTarballTooLarge, rel, hdr.Size, policy.maxFileSize())
+			}
+			total += hdr.Size
+			if total > policy.maxSize() {
+				return nil, fmt.Errorf("%w: the files exceed %d bytes", errorTarballTooLarge, policy.maxSize())
+			}
+
+			h := sha256.New()
+			if _, err := io.Copy(h, io.LimitReader(tr, hdr.Size)); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", errorInvalidTarball, rel, err)
+			}
+			file.Digest = slsa.DigestSet{"sha256": hex.EncodeToString(h.Sum(nil))}
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) {
+				return nil, fmt.Errorf("%w: %s links to the absolute path %s", errorInvalidTarball, rel, hdr.Linkname)
+			}
+			if _, err := tarballPath(path.Join(path.Dir(path.Join(tarballRoot, rel)), hdr.Linkname)); err != nil {
+				return nil, fmt.Errorf("%w: %s links outside of the package", errorInvalidTarball, rel)
+			}
+			file.Link = hdr.Linkname
+		case tar.TypeLink:
+			if _, err := tarballPath(hdr.Linkname); err != nil {
+				return nil, fmt.Errorf("%w: %s links outside of the package", errorInvalidTarball, rel)
+			}
+			file.Link = hdr.Linkname
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			return nil, fmt.Errorf("%w: %s is a device file", errorInvalidTarball, rel)
+		default:
+			return nil, fmt.Errorf("%w: %s has unsupported type %q", errorInvalidTarball, rel, hdr.Typeflag)
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return m, nil
+}
+
+// tarballPath returns the path of an entry of the tarball relative
+// to the package/ directory. It fails if the entry is outside of it.
+func tarballPath(name string) (string, error) {
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("%w: absolute path %s", errorInvalidTarball, name)
+	}
+	clean := path.Clean(name)
+	if clean == tarballRoot {
+		return "", nil
+	}
+	if !strings.HasPrefix(clean, tarballRoot+"/") {
+		return "", fmt.Errorf("%w: %s is not under %s/", errorInvalidTarball, name, tarballRoot)
+	}
+	return strings.TrimPrefix(clean, tarballRoot+"/"), nil
+}
+
+// WriteManifest writes the manifest of the files of the tarball to dir,
+// and returns its name and digests, recorded as a byproduct in the
+// provenance.
+func WriteManifest(dir string, m *Manifest) (intoto.Subject, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return intoto.Subject{}, fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return intoto.Subject{}, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	name := strings.TrimSuffix(m.Package, filepath.Ext(m.Package)) + manifestExt
+	if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+		return intoto.Subject{}, fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return NewSubject(name, bytesDigests(b)), nil
+}
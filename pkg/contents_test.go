@@ -0,0 +1,292 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+// writeTestTarball writes a gzipped tarball with the entries to dir.
+func writeTestTarball(t *testing.T, dir string, entries []tarEntry) string {
+	t.Helper()
+
+	fn := filepath.Join(dir, "test-1.0.1.tgz")
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatalf("os.Create: %v", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: typeflag,
+			Mode:     0o644,
+			Size:     int64(len(e.content)),
+			Linkname: e.linkname,
+		}
+		if typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar.Writer.WriteHeader: %v", err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatalf("tar.Writer.Write: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar.Writer.Close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip.Writer.Close: %v", err)
+	}
+	return fn
+}
+
+func Test_inspectTarball(t *testing.T) {
+	t.Parallel()
+
+	valid := []tarEntry{
+		{name: "package/package.json", content: `{"name":"test"}`},
+		{name: "package/lib/index.js", content: "module.exports = 1;\n"},
+	}
+
+	tests := []struct {
+		name     string
+		entries  []tarEntry
+		policy   ContentPolicy
+		expected error
+	}{
+		{
+			name:    "valid",
+			entries: valid,
+		},
+		{
+			name: "directories and links inside the package",
+			entries: append([]tarEntry{
+				{name: "package/", typeflag: tar.TypeDir},
+				{name: "package/lib/", typeflag: tar.TypeDir},
+				{name: "package/lib/main.js", typeflag: tar.TypeSymlink, linkname: "index.js"},
+				{name: "package/bin/cli.js", typeflag: tar.TypeSymlink, linkname: "../lib/index.js"},
+				{name: "package/lib/copy.js", typeflag: tar.TypeLink, linkname: "package/lib/index.js"},
+			}, valid...),
+		},
+		{
+			name:     "absolute path",
+			entries:  []tarEntry{{name: "/etc/passwd", content: "root"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "outside of the package",
+			entries:  []tarEntry{{name: "package/../index.js", content: "x"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "other root directory",
+			entries:  []tarEntry{{name: "other/index.js", content: "x"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "symbolic link outside of the package",
+			entries:  []tarEntry{{name: "package/lib/evil.js", typeflag: tar.TypeSymlink, linkname: "../../evil.js"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "absolute symbolic link",
+			entries:  []tarEntry{{name: "package/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "hard link outside of the package",
+			entries:  []tarEntry{{name: "package/passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "device file",
+			entries:  []tarEntry{{name: "package/null", typeflag: tar.TypeChar}},
+			expected: errorInvalidTarball,
+		},
+		{
+			name:     "environment file",
+			entries:  append([]tarEntry{{name: "package/.env.production", content: "TOKEN=secret"}}, valid...),
+			expected: errorForbiddenFile,
+		},
+		{
+			name:     "nested private key",
+			entries:  append([]tarEntry{{name: "package/config/server.pem", content: "-----BEGIN"}}, valid...),
+			expected: errorForbiddenFile,
+		},
+		{
+			name:     "git directory",
+			entries:  append([]tarEntry{{name: "package/.git/config", content: "[core]"}}, valid...),
+			expected: errorForbiddenFile,
+		},
+		{
+			name:    "allowed private key",
+			entries: append([]tarEntry{{name: "package/test/fixtures/server.pem", content: "-----BEGIN"}}, valid...),
+			policy:  ContentPolicy{Allow: []string{"test/fixtures/*.pem"}},
+		},
+		{
+			name:     "denied fixtures",
+			entries:  append([]tarEntry{{name: "package/test/fixtures/data.json", content: "{}"}}, valid...),
+			policy:   ContentPolicy{Deny: []string{"test/fixtures/"}},
+			expected: errorForbiddenFile,
+		},
+		{
+			name:     "file too large",
+			entries:  valid,
+			policy:   ContentPolicy{MaxFileSize: 16},
+			expected: errorTarballTooLarge,
+		},
+		{
+			name:     "files too large",
+			entries:  valid,
+			policy:   ContentPolicy{MaxSize: 32},
+			expected: errorTarballTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fn := writeTestTarball(t, t.TempDir(), tt.entries)
+			_, err := inspectTarball(fn, &tt.policy)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_inspectTarball_manifest(t *testing.T) {
+	t.Parallel()
+
+	fn := writeTestTarball(t, t.TempDir(), []tarEntry{
+		{name: "package/package.json", content: `{"name":"test"}`},
+		{name: "package/bin/cli.js", typeflag: tar.TypeSymlink, linkname: "../index.js"},
+		{name: "package/index.js", content: "module.exports = 1;\n"},
+	})
+
+	m, err := inspectTarball(fn, &ContentPolicy{})
+	if err != nil {
+		t.Fatalf("inspectTarball: %v", err)
+	}
+
+	expected := &Manifest{
+		Package: "test-1.0.1.tgz",
+		Files: []ManifestFile{
+			{Path: "bin/cli.js", Mode: "0644", Link: "../index.js"},
+			{
+				Path:   "index.js",
+				Size:   20,
+				Mode:   "0644",
+				Digest: slsa.DigestSet{"sha256": "6e66e366f0aefb84ad8110afcd9b2245702c643c831edf8316ff048fec739d2e"},
+			},
+			{
+				Path:   "package.json",
+				Size:   15,
+				Mode:   "0644",
+				Digest: slsa.DigestSet{"sha256": "7d9fd2051fc32b32feab10946fab6bb91426ab7e39aa5439289ed892864aa91d"},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, m); diff != "" {
+		t.Errorf(diff)
+	}
+
+	dir := t.TempDir()
+	s, err := WriteManifest(dir, m)
+	if err != nil {
+		t.Fatalf("WriteManifest: %v", err)
+	}
+	if diff := cmp.Diff("test-1.0.1.manifest.json", s.Name); diff != "" {
+		t.Errorf(diff)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, s.Name))
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if diff := cmp.Diff(bytesDigests(b), slsa.DigestSet(s.Digest)); diff != "" {
+		t.Errorf(diff)
+	}
+
+	var w Manifest
+	if err := json.Unmarshal(b, &w); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if diff := cmp.Diff(m, &w); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func Test_matchContentPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		pattern  string
+		path     string
+		dir      bool
+		expected bool
+	}{
+		{name: "name", pattern: ".env*", path: ".env", expected: true},
+		{name: "nested name", pattern: ".env*", path: "config/.env.local", expected: true},
+		{name: "name of a directory", pattern: "secrets", path: "secrets/a.json", expected: true},
+		{name: "other name", pattern: ".env*", path: "lib/env.js"},
+		{name: "directory", pattern: ".git/", path: ".git/config", expected: true},
+		{name: "directory entry", pattern: ".git/", path: "lib/.git", dir: true, expected: true},
+		{name: "directory pattern on a file", pattern: ".git/", path: ".git"},
+		{name: "path", pattern: "test/fixtures/*", path: "test/fixtures/a.json", expected: true},
+		{name: "path prefix", pattern: "test/fixtures", path: "test/fixtures/a.json", expected: true},
+		{name: "path at another depth", pattern: "test/fixtures/*", path: "lib/test/fixtures/a.json"},
+		{name: "path directory", pattern: "test/fixtures/", path: "test/fixtures/a.json", expected: true},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, matchContentPattern(tt.pattern, tt.path, tt.dir)); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
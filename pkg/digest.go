@@ -0,0 +1,168 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorInvalidDigest     = errors.New("invalid digest")
+	errorMissingStrongHash = errors.New("sha256 or sha512 digest required")
+)
+
+// Supported digest algorithms, from strongest to weakest.
+// npm records sha512 in `integrity` and sha1 in the legacy `shasum`.
+var digestAlgorithms = []string{"sha512", "sha256", "sha1"}
+
+var digestSizes = map[string]int{
+	"sha512": sha512.Size,
+	"sha256": sha256.Size,
+	"sha1":   sha1.Size,
+}
+
+// parseDigest parses a digest in hex, e.g. the output of `sha256sum`,
+// or in the Subresource Integrity format used by npm, e.g.
+// `sha512-<base64>`. It returns the algorithm and the hex-encoded value.
+func parseDigest(s string) (string, string, error) {
+	if parts := strings.SplitN(s, "-", 2); len(parts) == 2 {
+		alg, value := parts[0], parts[1]
+		size, exists := digestSizes[alg]
+		if !exists {
+			return "", "", fmt.Errorf("%w: unsupported algorithm: %s", errorInvalidDigest, s)
+		}
+
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(b) != size {
+			return "", "", fmt.Errorf("%w: %s", errorInvalidDigest, s)
+		}
+		return alg, hex.EncodeToString(b), nil
+	}
+
+	// The algorithm of a hex digest is inferred from its size.
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %s", errorInvalidDigest, s)
+	}
+	for alg, size := range digestSizes {
+		if len(b) == size {
+			return alg, strings.ToLower(s), nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %s", errorInvalidDigest, s)
+}
+
+// DigestSetFromString parses a list of digests separated by
+// spaces or commas, each in hex or SRI format.
+func DigestSetFromString(s string) (slsa.DigestSet, error) {
+	d := make(slsa.DigestSet)
+	for _, e := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		alg, value, err := parseDigest(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if v, exists := d[alg]; exists && v != value {
+			return nil, fmt.Errorf("%w: conflicting %s digests", errorInvalidDigest, alg)
+		}
+		d[alg] = value
+	}
+
+	if len(d) == 0 {
+		return nil, fmt.Errorf("%w: empty digest", errorInvalidDigest)
+	}
+	return d, nil
+}
+
+// IntegrityFromDigestSet returns the SRI string matching a digest set,
+// e.g. the `integrity` field of a package in the npm registry.
+// Hashes are listed from the strongest to the weakest.
+func IntegrityFromDigestSet(d slsa.DigestSet) string {
+	var sri []string
+	for _, alg := range digestAlgorithms {
+		value, exists := d[alg]
+		if !exists {
+			continue
+		}
+
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		sri = append(sri, fmt.Sprintf("%s-%s", alg, base64.StdEncoding.EncodeToString(b)))
+	}
+	return strings.Join(sri, " ")
+}
+
+// validateDigestSet verifies each digest of the set, and that the set
+// contains at least one digest that is not vulnerable to collisions.
+func validateDigestSet(d slsa.DigestSet) error {
+	for alg, value := range d {
+		size, exists := digestSizes[alg]
+		if !exists {
+			return fmt.Errorf("%w: unsupported algorithm: %s", errorInvalidDigest, alg)
+		}
+
+		if b, err := hex.DecodeString(value); err != nil || len(b) != size {
+			return fmt.Errorf("%w: %s digest is not valid: %s", errorInvalidDigest, alg, value)
+		}
+	}
+
+	if d["sha256"] == "" && d["sha512"] == "" {
+		return errorMissingStrongHash
+	}
+	return nil
+}
+
+// fileDigests returns the digests of a file for all supported algorithms.
+func fileDigests(fn string) (slsa.DigestSet, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	hashes := map[string]hash.Hash{
+		"sha512": sha512.New(),
+		"sha256": sha256.New(),
+		"sha1":   sha1.New(),
+	}
+
+	var writers []io.Writer
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, fmt.Errorf("io.Copy: %w", err)
+	}
+
+	d := make(slsa.DigestSet)
+	for alg, h := range hashes {
+		d[alg] = hex.EncodeToString(h.Sum(nil))
+	}
+	return d, nil
+}
@@ -0,0 +1,164 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+// Digests of the string "hello world".
+const (
+	helloSHA1   = "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"
+	helloSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	helloSHA512 = "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f" +
+		"989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+	helloSRI512 = "sha512-MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw=="
+	helloSRI256 = "sha256-uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="
+	helloSRI1   = "sha1-Kq5sNclPz7QV2+lfQIuc6R7oRu0="
+)
+
+func Test_DigestSetFromString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    string
+		expected struct {
+			err     error
+			digests slsa.DigestSet
+		}
+	}{
+		{
+			name:  "hex sha256",
+			value: helloSHA256,
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				digests: slsa.DigestSet{"sha256": helloSHA256},
+			},
+		},
+		{
+			name:  "npm integrity",
+			value: helloSRI512,
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				digests: slsa.DigestSet{"sha512": helloSHA512},
+			},
+		},
+		{
+			name:  "mixed list",
+			value: helloSRI512 + " " + helloSHA256 + "," + helloSHA1,
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				digests: slsa.DigestSet{
+					"sha512": helloSHA512,
+					"sha256": helloSHA256,
+					"sha1":   helloSHA1,
+				},
+			},
+		},
+		{
+			name:  "invalid hex size",
+			value: helloSHA256[:60],
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				err: errorInvalidDigest,
+			},
+		},
+		{
+			name:  "unsupported algorithm",
+			value: "md5-XrY7u+Ae7tCTyyK7j1rNww==",
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				err: errorInvalidDigest,
+			},
+		},
+		{
+			name:  "conflicting digests",
+			value: helloSHA256 + " sha256-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				err: errorInvalidDigest,
+			},
+		},
+		{
+			name:  "empty",
+			value: " ",
+			expected: struct {
+				err     error
+				digests slsa.DigestSet
+			}{
+				err: errorInvalidDigest,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := DigestSetFromString(tt.value)
+			if !errCmp(err, tt.expected.err) {
+				t.Errorf(cmp.Diff(err, tt.expected.err))
+			}
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(d, tt.expected.digests) {
+				t.Errorf(cmp.Diff(d, tt.expected.digests))
+			}
+		})
+	}
+}
+
+func Test_IntegrityFromDigestSet(t *testing.T) {
+	t.Parallel()
+
+	d := slsa.DigestSet{
+		"sha1":   helloSHA1,
+		"sha256": helloSHA256,
+		"sha512": helloSHA512,
+	}
+	expected := helloSRI512 + " " + helloSRI256 + " " + helloSRI1
+
+	r := IntegrityFromDigestSet(d)
+	if !cmp.Equal(r, expected) {
+		t.Errorf(cmp.Diff(r, expected))
+	}
+
+	// The SRI string round-trips to the same digest set.
+	rd, err := DigestSetFromString(r)
+	if err != nil {
+		t.Fatalf("DigestSetFromString: %v", err)
+	}
+	if !cmp.Equal(rd, d) {
+		t.Errorf(cmp.Diff(rd, d))
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"os"
+	"runtime"
+)
+
+// BuildEnvironment describes the runner the build ran on
+// and the toolchain it used.
+type BuildEnvironment struct {
+	// RunnerOS and RunnerArch are set by GitHub Actions,
+	// e.g. Linux and X64.
+	RunnerOS   string `json:"runnerOS"`
+	RunnerArch string `json:"runnerArch"`
+	// ImageOS and ImageVersion identify the virtual environment
+	// of GitHub-hosted runners, e.g. ubuntu20 and 20220425.1.
+	// They are not set on self-hosted runners.
+	ImageOS      string `json:"imageOS,omitempty"`
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// GOARCH is the architecture the builder binary was compiled for.
+	GOARCH      string `json:"goarch"`
+	NodeVersion string `json:"nodeVersion"`
+	NpmVersion  string `json:"npmVersion"`
+}
+
+// runnerEnvironment returns the environment of the runner,
+// with the versions of the toolchain.
+func runnerEnvironment(t *Toolchain) *BuildEnvironment {
+	return &BuildEnvironment{
+		RunnerOS:     os.Getenv("RUNNER_OS"),
+		RunnerArch:   os.Getenv("RUNNER_ARCH"),
+		ImageOS:      os.Getenv("ImageOS"),
+		ImageVersion: os.Getenv("ImageVersion"),
+		GOARCH:       runtime.GOARCH,
+		NodeVersion:  t.NodeVersion,
+		NpmVersion:   t.NpmVersion,
+	}
+}
+
+// values returns the environment as recorded in the provenance.
+func (e *BuildEnvironment) values() map[string]interface{} {
+	v := map[string]interface{}{
+		"os":           e.RunnerOS,
+		"arch":         e.RunnerArch,
+		"goarch":       e.GOARCH,
+		"node_version": e.NodeVersion,
+		"npm_version":  e.NpmVersion,
+	}
+	// Not set on self-hosted runners.
+	if e.ImageOS != "" {
+		v["image_os"] = e.ImageOS
+	}
+	if e.ImageVersion != "" {
+		v["image_version"] = e.ImageVersion
+	}
+	return v
+}
@@ -0,0 +1,80 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_BuildEnvironment_values(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		environment BuildEnvironment
+		expected    map[string]interface{}
+	}{
+		{
+			name: "github-hosted runner",
+			environment: BuildEnvironment{
+				RunnerOS:     "Linux",
+				RunnerArch:   "X64",
+				ImageOS:      "ubuntu20",
+				ImageVersion: "20220425.1",
+				GOARCH:       "amd64",
+				NodeVersion:  "v16.15.0",
+				NpmVersion:   "8.5.5",
+			},
+			expected: map[string]interface{}{
+				"os":            "Linux",
+				"arch":          "X64",
+				"image_os":      "ubuntu20",
+				"image_version": "20220425.1",
+				"goarch":        "amd64",
+				"node_version":  "v16.15.0",
+				"npm_version":   "8.5.5",
+			},
+		},
+		{
+			name: "self-hosted runner",
+			environment: BuildEnvironment{
+				RunnerOS:    "Linux",
+				RunnerArch:  "ARM64",
+				GOARCH:      "arm64",
+				NodeVersion: "v18.1.0",
+				NpmVersion:  "8.8.0",
+			},
+			expected: map[string]interface{}{
+				"os":           "Linux",
+				"arch":         "ARM64",
+				"goarch":       "arm64",
+				"node_version": "v18.1.0",
+				"npm_version":  "8.8.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, tt.environment.values()); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
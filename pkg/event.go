@@ -0,0 +1,230 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Redaction policies for the event payload recorded in the provenance.
+// The provenance is uploaded to a public transparency log, so email
+// addresses are always removed.
+const (
+	// RedactionMinimal keeps only the fields verifiers need
+	// to identify the trigger of the build.
+	RedactionMinimal = "minimal"
+	// RedactionStandard also keeps human-readable fields,
+	// such as commit messages and pull request titles.
+	RedactionStandard = "standard"
+)
+
+var (
+	errorUnsupportedRedactionPolicy = errors.New("redaction policy not supported")
+	errorInvalidEventPayload        = errors.New("invalid event payload")
+)
+
+// Typed models of the event payloads, limited to the fields that may
+// be recorded in the provenance. Unknown fields are dropped when the
+// payload is parsed.
+// See https://docs.github.com/en/developers/webhooks-and-events/webhooks/webhook-events-and-payloads.
+type (
+	EventRepository struct {
+		ID            int64  `json:"id"`
+		FullName      string `json:"full_name"`
+		HTMLURL       string `json:"html_url,omitempty"`
+		Private       bool   `json:"private"`
+		Fork          bool   `json:"fork"`
+		DefaultBranch string `json:"default_branch,omitempty"`
+	}
+
+	EventUser struct {
+		Login string `json:"login"`
+		ID    int64  `json:"id"`
+		Type  string `json:"type,omitempty"`
+	}
+
+	// GitActor is the author, committer or pusher of a commit.
+	GitActor struct {
+		Name     string `json:"name,omitempty"`
+		Email    string `json:"email,omitempty"`
+		Username string `json:"username,omitempty"`
+	}
+
+	EventCommit struct {
+		ID        string    `json:"id"`
+		TreeID    string    `json:"tree_id"`
+		Timestamp string    `json:"timestamp,omitempty"`
+		Message   string    `json:"message,omitempty"`
+		Author    *GitActor `json:"author,omitempty"`
+		Committer *GitActor `json:"committer,omitempty"`
+	}
+
+	PushEvent struct {
+		Ref        string          `json:"ref"`
+		Before     string          `json:"before"`
+		After      string          `json:"after"`
+		Created    bool            `json:"created"`
+		Deleted    bool            `json:"deleted"`
+		Forced     bool            `json:"forced"`
+		BaseRef    *string         `json:"base_ref"`
+		HeadCommit *EventCommit    `json:"head_commit,omitempty"`
+		Repository EventRepository `json:"repository"`
+		Pusher     *GitActor       `json:"pusher,omitempty"`
+		Sender     EventUser       `json:"sender"`
+	}
+
+	EventRelease struct {
+		ID              int64     `json:"id"`
+		TagName         string    `json:"tag_name"`
+		TargetCommitish string    `json:"target_commitish"`
+		Name            string    `json:"name,omitempty"`
+		Body            string    `json:"body,omitempty"`
+		Draft           bool      `json:"draft"`
+		Prerelease      bool      `json:"prerelease"`
+		HTMLURL         string    `json:"html_url,omitempty"`
+		Author          EventUser `json:"author"`
+	}
+
+	ReleaseEvent struct {
+		Action     string          `json:"action"`
+		Release    EventRelease    `json:"release"`
+		Repository EventRepository `json:"repository"`
+		Sender     EventUser       `json:"sender"`
+	}
+
+	WorkflowDispatchEvent struct {
+		Ref        string                 `json:"ref"`
+		Workflow   string                 `json:"workflow"`
+		Inputs     map[string]interface{} `json:"inputs"`
+		Repository EventRepository        `json:"repository"`
+		Sender     EventUser              `json:"sender"`
+	}
+
+	EventPullRequestBranch struct {
+		Ref  string          `json:"ref"`
+		SHA  string          `json:"sha"`
+		Repo EventRepository `json:"repo"`
+	}
+
+	EventPullRequest struct {
+		ID      int64                  `json:"id"`
+		Number  int                    `json:"number"`
+		State   string                 `json:"state"`
+		Title   string                 `json:"title,omitempty"`
+		Body    string                 `json:"body,omitempty"`
+		Draft   bool                   `json:"draft"`
+		Merged  bool                   `json:"merged"`
+		HTMLURL string                 `json:"html_url,omitempty"`
+		User    EventUser              `json:"user"`
+		Head    EventPullRequestBranch `json:"head"`
+		Base    EventPullRequestBranch `json:"base"`
+	}
+
+	PullRequestEvent struct {
+		Action      string           `json:"action"`
+		Number      int              `json:"number"`
+		PullRequest EventPullRequest `json:"pull_request"`
+		Repository  EventRepository  `json:"repository"`
+		Sender      EventUser        `json:"sender"`
+	}
+)
+
+type eventPayload interface {
+	redact(policy string)
+}
+
+// parseEventPayload parses the payload of the event that triggered
+// the workflow and redacts it according to the policy. The payload of
+// unsupported events is not recorded.
+func parseEventPayload(eventName string, payload json.RawMessage, policy string) (interface{}, error) {
+	switch policy {
+	case "":
+		policy = RedactionMinimal
+	case RedactionMinimal, RedactionStandard:
+	default:
+		return nil, fmt.Errorf("%w: %s", errorUnsupportedRedactionPolicy, policy)
+	}
+
+	var event eventPayload
+	switch eventName {
+	case "push":
+		event = &PushEvent{}
+	case "release":
+		event = &ReleaseEvent{}
+	case "workflow_dispatch":
+		event = &WorkflowDispatchEvent{}
+	case "pull_request", "pull_request_target":
+		event = &PullRequestEvent{}
+	default:
+		return nil, nil
+	}
+
+	if len(payload) == 0 {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errorInvalidEventPayload, eventName, err)
+	}
+
+	event.redact(policy)
+	return event, nil
+}
+
+func (a *GitActor) redact(policy string) *GitActor {
+	if a == nil || policy == RedactionMinimal {
+		return nil
+	}
+	a.Email = ""
+	return a
+}
+
+func (c *EventCommit) redact(policy string) {
+	if c == nil {
+		return
+	}
+	c.Author = c.Author.redact(policy)
+	c.Committer = c.Committer.redact(policy)
+	if policy == RedactionMinimal {
+		c.Message = ""
+	}
+}
+
+func (e *PushEvent) redact(policy string) {
+	e.HeadCommit.redact(policy)
+	e.Pusher = e.Pusher.redact(policy)
+}
+
+func (e *ReleaseEvent) redact(policy string) {
+	// Release notes are free-form text.
+	e.Release.Body = ""
+	if policy == RedactionMinimal {
+		e.Release.Name = ""
+	}
+}
+
+func (e *WorkflowDispatchEvent) redact(policy string) {
+	// Inputs are parameters of the build: they are always recorded.
+}
+
+func (e *PullRequestEvent) redact(policy string) {
+	// Descriptions are free-form text.
+	e.PullRequest.Body = ""
+	if policy == RedactionMinimal {
+		e.PullRequest.Title = ""
+	}
+}
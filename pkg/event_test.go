@@ -0,0 +1,186 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseEventPayload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		eventName string
+		path      string
+		policy    string
+		expected  struct {
+			err      error
+			contains []string
+			excludes []string
+		}
+	}{
+		{
+			name:      "push tag minimal",
+			eventName: "push",
+			path:      "./testdata/push_payload_tag.json",
+			policy:    RedactionMinimal,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"ref":"refs/tags/simple-tag"`, `"id":"6113728f27ae82c7b1a177c8d03f9e96e0adf246"`},
+				excludes: []string{"email", "Adding a .gitignore file", "pusher", "author"},
+			},
+		},
+		{
+			name:      "push branch standard",
+			eventName: "push",
+			path:      "./testdata/push_payload_notag.json",
+			policy:    RedactionStandard,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"message":"Update dummy"`, `"username":"laurentsimon"`},
+				excludes: []string{"email", "users.noreply.github.com"},
+			},
+		},
+		{
+			name:      "release minimal",
+			eventName: "release",
+			path:      "./testdata/release_payload.json",
+			policy:    "",
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"tag_name":"v1.0.1"`, `"target_commitish":"main"`},
+				excludes: []string{"jane.doe@example.com", "body"},
+			},
+		},
+		{
+			name:      "workflow_dispatch",
+			eventName: "workflow_dispatch",
+			path:      "./testdata/workflow_dispatch_payload.json",
+			policy:    RedactionMinimal,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"workflow":".github/workflows/event.yml"`, `"inputs":null`},
+				excludes: []string{`"organization":`, "avatar_url"},
+			},
+		},
+		{
+			name:      "pull_request standard",
+			eventName: "pull_request",
+			path:      "./testdata/pull_request_payload.json",
+			policy:    RedactionStandard,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"title":"Update the README with new information."`, `"sha":"ec26c3e57ca3a959ca5aad62de7213c562f8c821"`},
+				excludes: []string{"codertocat@example.com", "body"},
+			},
+		},
+		{
+			name:      "pull_request_target minimal",
+			eventName: "pull_request_target",
+			path:      "./testdata/pull_request_payload.json",
+			policy:    RedactionMinimal,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{`"number":2`},
+				excludes: []string{"title", "body"},
+			},
+		},
+		{
+			name:      "unsupported event",
+			eventName: "issue_comment",
+			path:      "./testdata/pull_request_payload.json",
+			policy:    RedactionMinimal,
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				contains: []string{"null"},
+			},
+		},
+		{
+			name:      "unsupported policy",
+			eventName: "push",
+			path:      "./testdata/push_payload_tag.json",
+			policy:    "none",
+			expected: struct {
+				err      error
+				contains []string
+				excludes []string
+			}{
+				err: errorUnsupportedRedactionPolicy,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			payload, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatalf("os.ReadFile: %v", err)
+			}
+
+			event, err := parseEventPayload(tt.eventName, payload, tt.policy)
+			if !errCmp(err, tt.expected.err) {
+				t.Errorf(cmp.Diff(err, tt.expected.err))
+			}
+			if err != nil {
+				return
+			}
+
+			b, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+
+			for _, c := range tt.expected.contains {
+				if !strings.Contains(string(b), c) {
+					t.Errorf("%s not found in %s", c, string(b))
+				}
+			}
+			for _, e := range tt.expected.excludes {
+				if strings.Contains(string(b), e) {
+					t.Errorf("%s found in %s", e, string(b))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorUnpinnedGitDependency = errors.New("git dependency not pinned to a commit")
+	errorMissingIntegrity      = errors.New("tarball dependency without integrity")
+)
+
+// gitShorthands are the hosts of the shorthands npm accepts
+// for git repositories, e.g. github:org/repo#ref.
+var gitShorthands = map[string]string{
+	"github:":    "github.com",
+	"gitlab:":    "gitlab.com",
+	"bitbucket:": "bitbucket.org",
+}
+
+// isGitResolved returns true if a package is resolved
+// from a git repository rather than a tarball.
+func isGitResolved(resolved string) bool {
+	if strings.HasPrefix(resolved, "git+") || strings.HasPrefix(resolved, "git://") {
+		return true
+	}
+	for prefix := range gitShorthands {
+		if strings.HasPrefix(resolved, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitGitResolved splits the URL a package is resolved from into
+// the URL of its repository, with the git+ prefix of the SLSA spec,
+// and the ref, e.g. git+ssh://git@github.com/org/repo.git and a commit
+// for git+ssh://git@github.com/org/repo.git#<commit>.
+func splitGitResolved(resolved string) (string, string) {
+	repo := resolved
+	for prefix, host := range gitShorthands {
+		if strings.HasPrefix(repo, prefix) {
+			repo = fmt.Sprintf("https://%s/%s", host, strings.TrimPrefix(repo, prefix))
+			break
+		}
+	}
+	if !strings.HasPrefix(repo, "git+") {
+		repo = "git+" + repo
+	}
+
+	parts := strings.SplitN(repo, "#", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// isCommit returns true if ref is the sha1 of a commit.
+func isCommit(ref string) bool {
+	b, err := hex.DecodeString(ref)
+	return err == nil && len(b) == digestSizes["sha1"] && ref == strings.ToLower(ref)
+}
+
+// gitMaterial returns the URI and digest of a package resolved from
+// a git repository, e.g. git+https://github.com/org/repo.git@<commit>
+// and the sha1 of the commit. The package must be pinned to a commit.
+func gitMaterial(resolved string) (string, slsa.DigestSet, error) {
+	repo, ref := splitGitResolved(resolved)
+	if !isCommit(ref) {
+		return "", nil, fmt.Errorf("%w: %s", errorUnpinnedGitDependency, resolved)
+	}
+	return fmt.Sprintf("%s@%s", repo, ref), slsa.DigestSet{"sha1": ref}, nil
+}
+
+// evaluatePins verifies that the packages of the lockfile are pinned:
+// git dependencies to a commit, and tarballs to their integrity unless
+// the policy allows tarballs without integrity.
+func evaluatePins(l *Lockfile, policy *RegistryPolicy) error {
+	var unpinned, unverified []string
+	seen := make(map[string]bool)
+	for _, p := range l.Packages {
+		if p.Resolved == "" || seen[p.Resolved] {
+			continue
+		}
+		seen[p.Resolved] = true
+
+		switch {
+		case isGitResolved(p.Resolved):
+			if _, _, err := gitMaterial(p.Resolved); err != nil {
+				unpinned = append(unpinned, fmt.Sprintf("%s (%s)", p.Name, p.Resolved))
+			}
+		case p.Integrity == "" && !policy.AllowMissingIntegrity:
+			unverified = append(unverified, fmt.Sprintf("%s (%s)", p.Name, p.Resolved))
+		}
+	}
+
+	if len(unpinned) > 0 {
+		sort.Strings(unpinned)
+		return fmt.Errorf("%w: %s", errorUnpinnedGitDependency, strings.Join(unpinned, ", "))
+	}
+	if len(unverified) > 0 {
+		sort.Strings(unverified)
+		return fmt.Errorf("%w: %s", errorMissingIntegrity, strings.Join(unverified, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+const testCommit = "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0"
+
+func Test_gitMaterial(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		uri    string
+		digest slsa.DigestSet
+		err    error
+	}
+
+	tests := []struct {
+		name     string
+		resolved string
+		expected result
+	}{
+		{
+			name:     "git+ssh",
+			resolved: "git+ssh://git@github.com/org/a.git#" + testCommit,
+			expected: result{
+				uri:    "git+ssh://git@github.com/org/a.git@" + testCommit,
+				digest: slsa.DigestSet{"sha1": testCommit},
+			},
+		},
+		{
+			name:     "git+https",
+			resolved: "git+https://gitlab.com/org/a.git#" + testCommit,
+			expected: result{
+				uri:    "git+https://gitlab.com/org/a.git@" + testCommit,
+				digest: slsa.DigestSet{"sha1": testCommit},
+			},
+		},
+		{
+			name:     "git protocol",
+			resolved: "git://github.com/org/a.git#" + testCommit,
+			expected: result{
+				uri:    "git+git://github.com/org/a.git@" + testCommit,
+				digest: slsa.DigestSet{"sha1": testCommit},
+			},
+		},
+		{
+			name:     "github shorthand",
+			resolved: "github:org/a#" + testCommit,
+			expected: result{
+				uri:    "git+https://github.com/org/a@" + testCommit,
+				digest: slsa.DigestSet{"sha1": testCommit},
+			},
+		},
+		{
+			name:     "branch",
+			resolved: "git+ssh://git@github.com/org/a.git#main",
+			expected: result{err: errorUnpinnedGitDependency},
+		},
+		{
+			name:     "semver range",
+			resolved: "github:org/a#semver:^1.0.0",
+			expected: result{err: errorUnpinnedGitDependency},
+		},
+		{
+			name:     "no ref",
+			resolved: "git+https://github.com/org/a.git",
+			expected: result{err: errorUnpinnedGitDependency},
+		},
+		{
+			name:     "abbreviated commit",
+			resolved: "git+https://github.com/org/a.git#4f2ba2b9",
+			expected: result{err: errorUnpinnedGitDependency},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			uri, digest, err := gitMaterial(tt.resolved)
+			if !errCmp(err, tt.expected.err) {
+				t.Fatalf(cmp.Diff(err, tt.expected.err))
+			}
+			if diff := cmp.Diff(tt.expected.uri, uri); diff != "" {
+				t.Errorf(diff)
+			}
+			if diff := cmp.Diff(tt.expected.digest, digest); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_evaluatePins(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   RegistryPolicy
+		packages map[string]*LockedPackage
+		expected error
+	}{
+		{
+			name: "pinned",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "git+ssh://git@github.com/org/a.git#" + testCommit},
+				"node_modules/b": {Name: "b", Resolved: "https://example.com/b-2.0.0.tgz", Integrity: "sha512-AAAA"},
+				"node_modules/e": {Name: "e", Link: "packages/e"},
+			},
+		},
+		{
+			name: "unpinned git dependency",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "git+ssh://git@github.com/org/a.git#main"},
+			},
+			expected: errorUnpinnedGitDependency,
+		},
+		{
+			name: "unpinned git dependency with allowed missing integrity",
+			policy: RegistryPolicy{
+				AllowMissingIntegrity: true,
+			},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "github:org/a"},
+			},
+			expected: errorUnpinnedGitDependency,
+		},
+		{
+			name: "tarball without integrity",
+			packages: map[string]*LockedPackage{
+				"node_modules/b": {Name: "b", Resolved: "https://example.com/b-2.0.0.tgz"},
+			},
+			expected: errorMissingIntegrity,
+		},
+		{
+			name: "allowed tarball without integrity",
+			policy: RegistryPolicy{
+				AllowMissingIntegrity: true,
+			},
+			packages: map[string]*LockedPackage{
+				"node_modules/b": {Name: "b", Resolved: "https://example.com/b-2.0.0.tgz"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := evaluatePins(&Lockfile{Packages: tt.packages}, &tt.policy)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
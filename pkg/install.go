@@ -0,0 +1,136 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	errorMissingLockfile   = errors.New("missing lockfile")
+	errorLockfileOutOfSync = errors.New("lockfile out of sync with package.json")
+)
+
+// npmLockfiles are the lockfiles `npm ci` installs from.
+var npmLockfiles = []string{
+	"npm-shrinkwrap.json",
+	"package-lock.json",
+}
+
+// npmCacheDir is the npm cache used by the install. It is emptied
+// before the install, so that packages are not read from a cache
+// populated by another build. The path does not depend on the run,
+// so that the dry run outputs the same command as the build.
+func npmCacheDir() string {
+	return filepath.Join(os.TempDir(), "slsa-github-generator-node", "npm-cache")
+}
+
+// installCommand returns the command that installs the dependencies
+// from the lockfile. Install scripts are ignored unless the config
+// allows them to run. The audit and funding requests are disabled.
+// An offline install only reads the packages from the cache.
+func (b *NodeBuild) installCommand() []string {
+	scripts := "--ignore-scripts"
+	if b.cfg.InstallScripts.Run {
+		// Overrides the ignore-scripts setting of .npmrc.
+		scripts = "--ignore-scripts=false"
+	}
+	com := []string{b.node, b.npm, "ci", scripts, "--cache", npmCacheDir(), "--no-audit", "--no-fund"}
+	if b.cfg.Install.Offline {
+		com = append(com, "--offline")
+	}
+	return com
+}
+
+// checkLockfile verifies that the project in dir has an npm lockfile,
+// and that the dependencies of package.json resolve to versions in
+// the lockfile that satisfy their range.
+func checkLockfile(pkgJson *PkgJsonConfig, dir string) error {
+	found := false
+	for _, fn := range npmLockfiles {
+		if _, err := os.Stat(filepath.Join(dir, fn)); err == nil {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: npm ci requires npm-shrinkwrap.json or package-lock.json", errorMissingLockfile)
+	}
+
+	// npm lockfiles take precedence over the others.
+	l, err := lockfileFromDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, deps := range []struct {
+		m        map[string]string
+		optional bool
+	}{
+		{pkgJson.Dependencies, false},
+		{pkgJson.DevDependencies, false},
+		{pkgJson.OptionalDependencies, true},
+	} {
+		if err := l.checkDependencies(deps.m, deps.optional); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDependencies verifies that the ranges of deps resolve to
+// packages of the lockfile. Ranges that are not semver ranges,
+// e.g. URLs or aliases, are only required to resolve.
+func (l *Lockfile) checkDependencies(deps map[string]string, optional bool) error {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key, exists := l.Dependencies[name]
+		if !exists {
+			// Optional dependencies are not installed on all platforms.
+			if optional {
+				continue
+			}
+			return fmt.Errorf("%w: %s is not in the lockfile", errorLockfileOutOfSync, name)
+		}
+
+		p := l.Packages[key]
+		if p.Link != "" {
+			continue
+		}
+
+		r := deps[name]
+		v, err := parseSemver(p.Version)
+		if err != nil {
+			continue
+		}
+		ok, err := matchVersionRange(r, *v)
+		if err != nil {
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s@%s does not satisfy %q", errorLockfileOutOfSync, name, p.Version, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_checkLockfile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dir      string
+		pkgJson  PkgJsonConfig
+		expected error
+	}{
+		{
+			name: "in sync",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				Dependencies:    map[string]string{"a": "^1.0.0", "@scope/c": "^3.0.0", "e": "^0.0.1"},
+				DevDependencies: map[string]string{"d": "^4.0.0"},
+			},
+		},
+		{
+			name: "lockfile v1",
+			dir:  "./testdata/lockfile/npm-v1",
+			pkgJson: PkgJsonConfig{
+				Dependencies: map[string]string{"a": "^1.0.0", "@scope/c": "^3.0.0"},
+			},
+		},
+		{
+			name: "shrinkwrap",
+			dir:  "./testdata/lockfile/shrinkwrap",
+			pkgJson: PkgJsonConfig{
+				Dependencies: map[string]string{"a": "^1.0.0"},
+			},
+		},
+		{
+			name: "missing optional dependency",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				Dependencies:         map[string]string{"a": "^1.0.0"},
+				OptionalDependencies: map[string]string{"fsevents": "^2.0.0"},
+			},
+		},
+		{
+			name: "non-semver range",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				Dependencies: map[string]string{"a": "github:org/a#v1.0.0"},
+			},
+		},
+		{
+			name: "range not satisfied",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				Dependencies: map[string]string{"a": "^2.0.0"},
+			},
+			expected: errorLockfileOutOfSync,
+		},
+		{
+			name: "missing dependency",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				Dependencies: map[string]string{"f": "^1.0.0"},
+			},
+			expected: errorLockfileOutOfSync,
+		},
+		{
+			name: "missing dev dependency",
+			dir:  "./testdata/lockfile/npm-v3",
+			pkgJson: PkgJsonConfig{
+				DevDependencies: map[string]string{"f": "^1.0.0"},
+			},
+			expected: errorLockfileOutOfSync,
+		},
+		{
+			name:     "yarn lockfile",
+			dir:      "./testdata/lockfile/yarn-v1",
+			expected: errorMissingLockfile,
+		},
+		{
+			name:     "no lockfile",
+			dir:      "./testdata",
+			expected: errorMissingLockfile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkLockfile(&tt.pkgJson, tt.dir)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_NodeBuild_installCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		run      bool
+		offline  bool
+		expected []string
+	}{
+		{
+			name:     "ignore scripts",
+			expected: []string{"--ignore-scripts"},
+		},
+		{
+			name:     "run scripts",
+			run:      true,
+			expected: []string{"--ignore-scripts=false"},
+		},
+		{
+			name:     "offline",
+			offline:  true,
+			expected: []string{"--ignore-scripts", "--offline"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := NodeBuildNew(&Toolchain{NodePath: "node", NpmPath: "npm"},
+				&GoReleaserConfig{
+					InstallScripts: InstallScriptPolicy{Run: tt.run},
+					Install:        InstallConfig{Offline: tt.offline},
+				}, &PkgJsonConfig{}, "")
+			com := b.installCommand()
+
+			expected := []string{"node", "npm", "ci", tt.expected[0], "--cache", npmCacheDir(), "--no-audit", "--no-fund"}
+			expected = append(expected, tt.expected[1:]...)
+			if diff := cmp.Diff(expected, com); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorInvalidLockfile     = errors.New("invalid lockfile")
+	errorUnsupportedLockfile = errors.New("lockfile version not supported")
+)
+
+// Lockfile is the dependency graph of a project, as resolved in its
+// lockfile. It does not depend on the package manager that wrote it.
+type Lockfile struct {
+	// Packages are indexed by a key specific to the format of the
+	// lockfile, e.g. the path in node_modules for npm.
+	Packages map[string]*LockedPackage
+	// Dependencies maps the names of the direct dependencies of
+	// the project to their key. It is nil if the lockfile does not
+	// record them; see resolveRoot.
+	Dependencies map[string]string
+
+	// descriptors maps the descriptors of the dependencies, e.g.
+	// a@^1.0.0, to their key, for lockfiles indexed by descriptor.
+	descriptors map[string]string
+	// installScripts is true if the format of the lockfile records
+	// the packages with install scripts.
+	installScripts bool
+	// devPackages is true if the format of the lockfile records
+	// the packages only installed by devDependencies; see resolveRoot.
+	devPackages bool
+}
+
+// LockedPackage is a package resolved in a lockfile.
+type LockedPackage struct {
+	Name      string
+	Version   string
+	Resolved  string
+	Integrity string
+	Dev       bool
+	Optional  bool
+	// HasInstallScript is true if the package has preinstall,
+	// install or postinstall scripts.
+	HasInstallScript bool
+	// Link is the key of the package a link points to,
+	// e.g. a package of a workspace.
+	Link string
+	// Dependencies maps the names of the dependencies
+	// of the package to their key.
+	Dependencies map[string]string
+}
+
+// Supported lockfiles, by order of precedence.
+var lockfileParsers = []struct {
+	filename string
+	parse    func([]byte) (*Lockfile, error)
+}{
+	// See https://docs.npmjs.com/cli/v8/configuring-npm/package-lock-json.
+	{"npm-shrinkwrap.json", npmLockfileFromString},
+	{"package-lock.json", npmLockfileFromString},
+	{"yarn.lock", yarnLockfileFromString},
+	{"pnpm-lock.yaml", pnpmLockfileFromString},
+}
+
+// The registry used to resolve the tarball of packages when the
+// lockfile does not record it. Registries configured in .npmrc or
+// .yarnrc.yml are not taken into account.
+const npmRegistry = "https://registry.npmjs.org"
+
+// lockfileVersion 1 has a tree of dependencies. Versions 2 and 3
+// have a flat list of packages indexed by their path.
+type (
+	npmLockfile struct {
+		LockfileVersion int                              `json:"lockfileVersion"`
+		Packages        map[string]npmLockfilePackage    `json:"packages"`
+		Dependencies    map[string]npmLockfileDependency `json:"dependencies"`
+	}
+
+	npmLockfilePackage struct {
+		Name                 string            `json:"name"`
+		Version              string            `json:"version"`
+		Resolved             string            `json:"resolved"`
+		Integrity            string            `json:"integrity"`
+		Link                 bool              `json:"link"`
+		HasInstallScript     bool              `json:"hasInstallScript"`
+		Dev                  bool              `json:"dev"`
+		Optional             bool              `json:"optional"`
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+
+	npmLockfileDependency struct {
+		Version      string                           `json:"version"`
+		Resolved     string                           `json:"resolved"`
+		Integrity    string                           `json:"integrity"`
+		Dev          bool                             `json:"dev"`
+		Optional     bool                             `json:"optional"`
+		Requires     map[string]string                `json:"requires"`
+		Dependencies map[string]npmLockfileDependency `json:"dependencies"`
+	}
+)
+
+// dependencyMaterials returns the dependencies resolved in the
+// lockfile of the project in dir.
+func dependencyMaterials(pkgJson *PkgJsonConfig, dir string) ([]ResourceDescriptor, error) {
+	l, err := lockfileFromDir(dir)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	l.resolveRoot(pkgJson)
+	return l.materials(pkgJson.BundleDependencies)
+}
+
+// lockfileFromDir parses the lockfile of the project in dir.
+// It returns nil if the project has no lockfile.
+func lockfileFromDir(dir string) (*Lockfile, error) {
+	for _, lp := range lockfileParsers {
+		b, err := os.ReadFile(filepath.Join(dir, lp.filename))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		l, err := lp.parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lp.filename, err)
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+// resolveRoot sets the direct dependencies of the project from the
+// ranges in package.json, if the lockfile does not record them. It also
+// marks the packages not reachable from the dependencies and
+// optionalDependencies as dev, if the lockfile does not record them.
+func (l *Lockfile) resolveRoot(pkgJson *PkgJsonConfig) {
+	if l.Dependencies == nil {
+		l.Dependencies = make(map[string]string)
+		for _, deps := range []map[string]string{pkgJson.Dependencies, pkgJson.OptionalDependencies} {
+			for name, r := range deps {
+				if key, exists := l.resolveDescriptor(name, r); exists {
+					l.Dependencies[name] = key
+				}
+			}
+		}
+	}
+
+	if l.devPackages {
+		return
+	}
+	var names []string
+	for _, deps := range []map[string]string{pkgJson.Dependencies, pkgJson.OptionalDependencies} {
+		for name := range deps {
+			names = append(names, name)
+		}
+	}
+	prod := l.closure(names)
+	for key, p := range l.Packages {
+		p.Dev = !prod[key]
+	}
+}
+
+// resolveDescriptor returns the key of the package a dependency
+// on the range r of name resolves to.
+func (l *Lockfile) resolveDescriptor(name, r string) (string, bool) {
+	// Yarn Berry prefixes ranges of the npm registry with npm:.
+	for _, d := range []string{name + "@" + r, name + "@npm:" + r} {
+		if key, exists := l.descriptors[d]; exists {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// registryTarballURL returns the URL of the tarball of a package
+// in the npm registry. The tarball of @scope/a is named a-<version>.tgz.
+func registryTarballURL(name, version string) string {
+	return fmt.Sprintf("%s/%s/-/%s-%s.tgz", npmRegistry, name, path.Base(name), version)
+}
+
+// splitDescriptor splits a descriptor, e.g. @scope/a@^1.0.0,
+// into a name and a range.
+func splitDescriptor(d string) (string, string, bool) {
+	if len(d) < 2 {
+		return "", "", false
+	}
+	// The name of scoped packages starts with @.
+	i := strings.Index(d[1:], "@")
+	if i < 0 {
+		return "", "", false
+	}
+	return d[:i+1], d[i+2:], true
+}
+
+func npmLockfileFromString(b []byte) (*Lockfile, error) {
+	var lf npmLockfile
+	if err := json.Unmarshal(b, &lf); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidLockfile, err)
+	}
+
+	switch lf.LockfileVersion {
+	case 1:
+		return lf.fromDependencies(), nil
+	case 2, 3:
+		return lf.fromPackages()
+	default:
+		return nil, fmt.Errorf("%w: %d", errorUnsupportedLockfile, lf.LockfileVersion)
+	}
+}
+
+func (lf *npmLockfile) fromPackages() (*Lockfile, error) {
+	root, ok := lf.Packages[""]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing root package", errorInvalidLockfile)
+	}
+
+	l := &Lockfile{
+		Packages:       make(map[string]*LockedPackage),
+		installScripts: true,
+		devPackages:    true,
+	}
+	exists := func(key string) bool {
+		_, ok := lf.Packages[key]
+		return ok
+	}
+
+	for key, p := range lf.Packages {
+		if key == "" {
+			continue
+		}
+
+		name := p.Name
+		if name == "" {
+			name = npmNameFromPath(key)
+		}
+
+		lp := &LockedPackage{
+			Name:             name,
+			Version:          p.Version,
+			Resolved:         p.Resolved,
+			Integrity:        p.Integrity,
+			Dev:              p.Dev,
+			Optional:         p.Optional,
+			HasInstallScript: p.HasInstallScript,
+			Dependencies:     npmResolveAll(exists, key, p.Dependencies, p.DevDependencies, p.OptionalDependencies),
+		}
+		// The target of a link is the path of the package
+		// relative to the root of the project.
+		if p.Link {
+			lp.Link = p.Resolved
+			lp.Resolved = ""
+		}
+		l.Packages[key] = lp
+	}
+
+	l.Dependencies = npmResolveAll(exists, "", root.Dependencies, root.DevDependencies, root.OptionalDependencies)
+	return l, nil
+}
+
+func (lf *npmLockfile) fromDependencies() *Lockfile {
+	l := &Lockfile{
+		Packages:     make(map[string]*LockedPackage),
+		Dependencies: make(map[string]string),
+		devPackages:  true,
+	}
+	requires := make(map[string]map[string]string)
+
+	var walk func(parent string, deps map[string]npmLockfileDependency)
+	walk = func(parent string, deps map[string]npmLockfileDependency) {
+		for name, d := range deps {
+			key := path.Join(parent, "node_modules", name)
+			lp := &LockedPackage{
+				Name:      name,
+				Version:   d.Version,
+				Resolved:  d.Resolved,
+				Integrity: d.Integrity,
+				Dev:       d.Dev,
+				Optional:  d.Optional,
+			}
+			// The version of git dependencies is the URL
+			// of the repository and the commit.
+			if lp.Resolved == "" && isGitResolved(d.Version) {
+				lp.Resolved = d.Version
+			}
+			l.Packages[key] = lp
+			requires[key] = d.Requires
+			walk(key, d.Dependencies)
+		}
+	}
+	walk("", lf.Dependencies)
+
+	exists := func(key string) bool {
+		_, ok := l.Packages[key]
+		return ok
+	}
+	for key, p := range l.Packages {
+		p.Dependencies = npmResolveAll(exists, key, requires[key])
+	}
+
+	// Version 1 does not distinguish direct dependencies
+	// from the ones hoisted to the top of node_modules.
+	for name := range lf.Dependencies {
+		l.Dependencies[name] = path.Join("node_modules", name)
+	}
+	return l
+}
+
+// npmNameFromPath returns the name of the package installed at
+// a path, e.g. node_modules/a/node_modules/@scope/b is @scope/b.
+func npmNameFromPath(key string) string {
+	i := strings.LastIndex(key, "node_modules/")
+	if i < 0 {
+		return path.Base(key)
+	}
+	return key[i+len("node_modules/"):]
+}
+
+// npmResolve returns the key of the package a dependency resolves to,
+// following the lookup of node: node_modules of the package first, then
+// the node_modules of its ancestors.
+// See https://nodejs.org/api/modules.html#loading-from-node_modules-folders.
+func npmResolve(exists func(string) bool, from, name string) (string, bool) {
+	dir := from
+	for {
+		key := path.Join(dir, "node_modules", name)
+		if exists(key) {
+			return key, true
+		}
+		if dir == "" {
+			return "", false
+		}
+
+		i := strings.LastIndex(dir, "/node_modules/")
+		if i < 0 {
+			dir = ""
+		} else {
+			dir = dir[:i]
+		}
+	}
+}
+
+func npmResolveAll(exists func(string) bool, from string, deps ...map[string]string) map[string]string {
+	res := make(map[string]string)
+	for _, m := range deps {
+		for name := range m {
+			// Optional dependencies may not be installed.
+			if key, ok := npmResolve(exists, from, name); ok {
+				res[name] = key
+			}
+		}
+	}
+	return res
+}
+
+// bundled returns the keys of the packages included in the tarball
+// of the project: its bundleDependencies and their dependencies.
+// See https://docs.npmjs.com/cli/v8/configuring-npm/package-json#bundledependencies.
+func (l *Lockfile) bundled(bundleDependencies []string) map[string]bool {
+	return l.closure(bundleDependencies)
+}
+
+// closure returns the keys of the packages the direct dependencies
+// names depend on, including themselves.
+func (l *Lockfile) closure(names []string) map[string]bool {
+	res := make(map[string]bool)
+
+	var visit func(key string)
+	visit = func(key string) {
+		if res[key] {
+			return
+		}
+		p, exists := l.Packages[key]
+		if !exists {
+			return
+		}
+		res[key] = true
+
+		if p.Link != "" {
+			visit(p.Link)
+		}
+		for _, k := range p.Dependencies {
+			visit(k)
+		}
+	}
+
+	for _, name := range names {
+		if key, exists := l.Dependencies[name]; exists {
+			visit(key)
+		}
+	}
+	return res
+}
+
+// materials returns the packages downloaded from a registry or
+// another remote location, sorted by URI. Packages are annotated with
+// dev if they are only installed to build the package, and bundled if
+// they are included in the tarball. Other packages are installed by
+// the consumers of the package at runtime.
+func (l *Lockfile) materials(bundleDependencies []string) ([]ResourceDescriptor, error) {
+	bundled := l.bundled(bundleDependencies)
+
+	// The same package may be installed at several paths.
+	byURI := make(map[string]*ResourceDescriptor)
+	for key, p := range l.Packages {
+		// Links and packages of the workspace are part of the source.
+		if p.Resolved == "" {
+			continue
+		}
+
+		if m, exists := byURI[p.Resolved]; exists {
+			if bundled[key] {
+				m.Annotations["bundled"] = true
+			}
+			// A package installed at several paths is a runtime
+			// dependency if one of them is.
+			if !p.Dev {
+				m.Annotations["dev"] = false
+			}
+			continue
+		}
+
+		uri := p.Resolved
+		var gitDigest slsa.DigestSet
+		if isGitResolved(p.Resolved) {
+			var err error
+			if uri, gitDigest, err = gitMaterial(p.Resolved); err != nil {
+				return nil, err
+			}
+		}
+
+		m := &ResourceDescriptor{
+			URI:    uri,
+			Digest: gitDigest,
+			Name:   p.Name,
+			Annotations: map[string]interface{}{
+				"version": p.Version,
+				"bundled": bundled[key],
+				"dev":     p.Dev,
+			},
+		}
+		if p.HasInstallScript {
+			m.Annotations["hasInstallScript"] = true
+		}
+		if p.Integrity != "" {
+			digests, err := DigestSetFromString(p.Integrity)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", errorInvalidLockfile, key, err)
+			}
+			m.Digest = digests
+		}
+		byURI[p.Resolved] = m
+	}
+
+	uris := make([]string, 0, len(byURI))
+	for uri := range byURI {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	materials := make([]ResourceDescriptor, 0, len(uris))
+	for _, uri := range uris {
+		materials = append(materials, *byURI[uri])
+	}
+	return materials, nil
+}
@@ -0,0 +1,239 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pnpm-lock.yaml, in the formats of lockfileVersion 5.x, 6.x and 9.x.
+// Version 9 moved the dependencies of the packages to snapshots.
+// See https://github.com/pnpm/spec/tree/master/lockfile.
+type (
+	pnpmLockfile struct {
+		LockfileVersion      string                    `yaml:"lockfileVersion"`
+		Importers            map[string]pnpmImporter   `yaml:"importers"`
+		Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+		DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+		OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+		Packages             map[string]pnpmPackage    `yaml:"packages"`
+		Snapshots            map[string]pnpmSnapshot   `yaml:"snapshots"`
+	}
+
+	pnpmImporter struct {
+		Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+		DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+		OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+	}
+
+	// pnpmDependency is a direct dependency of the project: a version
+	// in lockfileVersion 5.x, an object with a version in later versions.
+	pnpmDependency struct {
+		Version string `yaml:"version"`
+	}
+
+	pnpmPackage struct {
+		Name                 string            `yaml:"name"`
+		Version              string            `yaml:"version"`
+		Resolution           pnpmResolution    `yaml:"resolution"`
+		Dependencies         map[string]string `yaml:"dependencies"`
+		OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+		Dev                  bool              `yaml:"dev"`
+		Optional             bool              `yaml:"optional"`
+		// RequiresBuild is set for packages with install scripts
+		// until lockfileVersion 9.x.
+		RequiresBuild bool `yaml:"requiresBuild"`
+	}
+
+	pnpmResolution struct {
+		Integrity string `yaml:"integrity"`
+		Tarball   string `yaml:"tarball"`
+		Repo      string `yaml:"repo"`
+		Commit    string `yaml:"commit"`
+	}
+
+	pnpmSnapshot struct {
+		Dependencies         map[string]string `yaml:"dependencies"`
+		OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+	}
+)
+
+func (d *pnpmDependency) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		d.Version = n.Value
+		return nil
+	}
+
+	type dependency pnpmDependency
+	return n.Decode((*dependency)(d))
+}
+
+func pnpmLockfileFromString(b []byte) (*Lockfile, error) {
+	var lf pnpmLockfile
+	if err := yaml.Unmarshal(b, &lf); err != nil {
+		return nil, fmt.Errorf("%w: yaml.Unmarshal: %v", errorInvalidLockfile, err)
+	}
+
+	major, err := strconv.Atoi(strings.SplitN(lf.LockfileVersion, ".", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", errorUnsupportedLockfile, lf.LockfileVersion)
+	}
+
+	switch major {
+	case 5, 6:
+		// The dependencies are recorded with the packages.
+		lf.Snapshots = make(map[string]pnpmSnapshot)
+		for key, p := range lf.Packages {
+			lf.Snapshots[key] = pnpmSnapshot{
+				Dependencies:         p.Dependencies,
+				OptionalDependencies: p.OptionalDependencies,
+			}
+		}
+	case 9:
+	default:
+		return nil, fmt.Errorf("%w: %s", errorUnsupportedLockfile, lf.LockfileVersion)
+	}
+
+	l := &Lockfile{
+		Packages:       make(map[string]*LockedPackage),
+		installScripts: major < 9,
+		devPackages:    major < 9,
+	}
+	for key, s := range lf.Snapshots {
+		name, version := pnpmNameVersion(key, major)
+		p := lf.Packages[key]
+		if major == 9 {
+			// Packages are not duplicated for each set of peer dependencies.
+			p = lf.Packages[name+"@"+version]
+		}
+		if p.Name != "" {
+			name = p.Name
+		}
+		if p.Version != "" {
+			version = p.Version
+		}
+
+		l.Packages[key] = &LockedPackage{
+			Name:             name,
+			Version:          version,
+			Resolved:         pnpmResolved(name, version, p.Resolution),
+			Integrity:        p.Resolution.Integrity,
+			Dev:              p.Dev,
+			Optional:         p.Optional,
+			HasInstallScript: p.RequiresBuild,
+			Dependencies:     lf.resolveAll(s.Dependencies, s.OptionalDependencies),
+		}
+	}
+
+	root := pnpmImporter{
+		Dependencies:         lf.Dependencies,
+		DevDependencies:      lf.DevDependencies,
+		OptionalDependencies: lf.OptionalDependencies,
+	}
+	if importer, exists := lf.Importers["."]; exists {
+		root = importer
+	}
+
+	l.Dependencies = make(map[string]string)
+	for _, deps := range []map[string]pnpmDependency{root.Dependencies, root.DevDependencies, root.OptionalDependencies} {
+		for name, d := range deps {
+			if key, exists := lf.resolve(name, d.Version); exists {
+				l.Dependencies[name] = key
+			}
+		}
+	}
+	return l, nil
+}
+
+// resolve returns the key of the package a dependency resolves to.
+// The version of a dependency is either the key of the package, e.g.
+// for aliases, or the version with the peer dependencies.
+func (lf *pnpmLockfile) resolve(name, version string) (string, bool) {
+	// Packages of the workspace are linked.
+	if strings.HasPrefix(version, "link:") {
+		return "", false
+	}
+
+	for _, key := range []string{
+		version,
+		// lockfileVersion 5.x.
+		"/" + name + "/" + version,
+		// lockfileVersion 6.x.
+		"/" + name + "@" + version,
+		// lockfileVersion 9.x.
+		name + "@" + version,
+	} {
+		if _, exists := lf.Snapshots[key]; exists {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func (lf *pnpmLockfile) resolveAll(deps ...map[string]string) map[string]string {
+	res := make(map[string]string)
+	for _, m := range deps {
+		for name, version := range m {
+			if key, exists := lf.resolve(name, version); exists {
+				res[name] = key
+			}
+		}
+	}
+	return res
+}
+
+// pnpmNameVersion returns the name and version of the package of a key,
+// e.g. /@scope/a/1.0.0_b@2.0.0 in lockfileVersion 5.x, /@scope/a@1.0.0(b@2.0.0)
+// in 6.x and @scope/a@1.0.0(b@2.0.0) in 9.x.
+func pnpmNameVersion(key string, major int) (string, string) {
+	k := strings.TrimPrefix(key, "/")
+
+	if major == 5 {
+		i := strings.LastIndex(k, "/")
+		if i < 0 {
+			return k, ""
+		}
+		// Peer dependencies are a suffix of the version.
+		return k[:i], strings.SplitN(k[i+1:], "_", 2)[0]
+	}
+
+	// Peer dependencies are a suffix of the key.
+	k = strings.SplitN(k, "(", 2)[0]
+	name, version, ok := splitDescriptor(k)
+	if !ok {
+		return k, ""
+	}
+	return name, version
+}
+
+// pnpmResolved returns the URL of a package. pnpm only records the
+// tarball of packages that are not in the registry.
+func pnpmResolved(name, version string, r pnpmResolution) string {
+	switch {
+	case r.Tarball != "":
+		return r.Tarball
+	case r.Repo != "":
+		return fmt.Sprintf("git+%s#%s", r.Repo, r.Commit)
+	case r.Integrity != "":
+		return registryTarballURL(name, version)
+	default:
+		// Packages of the workspace.
+		return ""
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_pnpmNameVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key     string
+		major   int
+		name    string
+		version string
+	}{
+		{key: "/a/1.0.0", major: 5, name: "a", version: "1.0.0"},
+		{key: "/@scope/c/3.1.0_b@2.0.0", major: 5, name: "@scope/c", version: "3.1.0"},
+		{key: "/a@1.0.0", major: 6, name: "a", version: "1.0.0"},
+		{key: "/@scope/c@3.1.0(b@2.0.0)", major: 6, name: "@scope/c", version: "3.1.0"},
+		{key: "@scope/c@3.1.0(b@2.0.0)(d@4.0.0)", major: 9, name: "@scope/c", version: "3.1.0"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			name, version := pnpmNameVersion(tt.key, tt.major)
+			if name != tt.name || version != tt.version {
+				t.Errorf("pnpmNameVersion(%q) = %s, %s, expected %s, %s", tt.key, name, version, tt.name, tt.version)
+			}
+		})
+	}
+}
+
+func Test_pnpmLockfileFromString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		lockfile string
+		expected error
+	}{
+		{
+			name:     "unsupported version",
+			lockfile: "lockfileVersion: '4.0'\n",
+			expected: errorUnsupportedLockfile,
+		},
+		{
+			name:     "missing version",
+			lockfile: "packages: {}\n",
+			expected: errorUnsupportedLockfile,
+		},
+		{
+			name:     "invalid yaml",
+			lockfile: "lockfileVersion: [\n",
+			expected: errorInvalidLockfile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := pnpmLockfileFromString([]byte(tt.lockfile))
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
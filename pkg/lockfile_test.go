@@ -0,0 +1,357 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+// Materials of the projects in testdata/lockfile, which
+// bundle the dependency a.
+var lockfileMaterials = []ResourceDescriptor{
+	{
+		URI: "https://registry.npmjs.org/@scope/c/-/c-3.1.0.tgz",
+		Digest: slsa.DigestSet{
+			"sha512": "cc11d15073e423826068054661dc9d42f65561e70918cc2aef2e6b913fff0b039b3d3c1f47c25afcfd4e9a99115d0a26a6dd08e724c43edd741a06a33338dde8",
+		},
+		Name:        "@scope/c",
+		Annotations: map[string]interface{}{"version": "3.1.0", "bundled": false, "dev": false},
+	},
+	{
+		URI: "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+		Digest: slsa.DigestSet{
+			"sha512": "06835a7792ff028890e5a3a30e7500634af20b137ccce8ba612b941c432156fbd8a3be430975df70f566e7ecc7e12e4e2e14c14b2ea9ce5ee744f89fe4727ece",
+		},
+		Name:        "a",
+		Annotations: map[string]interface{}{"version": "1.0.0", "bundled": true, "dev": false},
+	},
+	{
+		URI: "https://registry.npmjs.org/b/-/b-1.0.0.tgz",
+		Digest: slsa.DigestSet{
+			"sha512": "aefb938c747231ab9d22cfed8a45517b20896ec9118337d008bde178fd426ee9cdc7be22f346e417b28408fb8dd9cfac9af2112ef740ea453ba2372675637922",
+		},
+		Name:        "b",
+		Annotations: map[string]interface{}{"version": "1.0.0", "bundled": false, "dev": false},
+	},
+	{
+		URI: "https://registry.npmjs.org/b/-/b-2.0.0.tgz",
+		Digest: slsa.DigestSet{
+			"sha512": "55ebd79d06002284fa3160695fa32f94ad356b873a8a5564e63e43e574fff8cfc0b7598018f77f636e56185454544831ee75abc9231445cdbc6d4458de6790ae",
+		},
+		Name:        "b",
+		Annotations: map[string]interface{}{"version": "2.0.0", "bundled": true, "dev": false},
+	},
+	{
+		URI: "https://registry.npmjs.org/d/-/d-4.0.0.tgz",
+		Digest: slsa.DigestSet{
+			"sha512": "a7447ed9f4cc21900ceab6fa482dd65ed9554f346ad8fd4d32ec3c0c07b434157bdee8b523f105acad8ef252d94e6325868d091fa4fbd853ec90ab0dc1a4c65a",
+			"sha1":   "92f4c008ebdbc232b228bea7e59e5e00e71185b1",
+		},
+		Name:        "d",
+		Annotations: map[string]interface{}{"version": "4.0.0", "bundled": false, "dev": true},
+	},
+}
+
+// Yarn Berry does not record the integrity of packages.
+func withoutDigests(materials []ResourceDescriptor) []ResourceDescriptor {
+	var res []ResourceDescriptor
+	for _, m := range materials {
+		m.Digest = nil
+		res = append(res, m)
+	}
+	return res
+}
+
+func Test_dependencyMaterials(t *testing.T) {
+	t.Parallel()
+
+	// Yarn v1 does not record the direct dependencies.
+	dependencies := map[string]string{
+		"a":        "^1.0.0",
+		"@scope/c": "^3.0.0",
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		bundle    []string
+		materials []ResourceDescriptor
+		expected  error
+	}{
+		{
+			name:      "lockfile v1",
+			dir:       "./testdata/lockfile/npm-v1",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "lockfile v2",
+			dir:       "./testdata/lockfile/npm-v2",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "lockfile v3",
+			dir:       "./testdata/lockfile/npm-v3",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "shrinkwrap takes precedence",
+			dir:       "./testdata/lockfile/shrinkwrap",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "yarn v1",
+			dir:       "./testdata/lockfile/yarn-v1",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "yarn berry",
+			dir:       "./testdata/lockfile/yarn-berry",
+			bundle:    []string{"a"},
+			materials: withoutDigests(lockfileMaterials),
+		},
+		{
+			name:      "pnpm v5",
+			dir:       "./testdata/lockfile/pnpm-v5",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "pnpm v6",
+			dir:       "./testdata/lockfile/pnpm-v6",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name:      "pnpm v9",
+			dir:       "./testdata/lockfile/pnpm-v9",
+			bundle:    []string{"a"},
+			materials: lockfileMaterials,
+		},
+		{
+			name: "no lockfile",
+			dir:  "./testdata/toolchain",
+		},
+		{
+			name:     "unsupported version",
+			dir:      "./testdata/lockfile/unsupported",
+			expected: errorUnsupportedLockfile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			materials, err := dependencyMaterials(&PkgJsonConfig{
+				BundleDependencies: tt.bundle,
+				Dependencies:       dependencies,
+			}, tt.dir)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.materials, materials); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_Lockfile_bundled(t *testing.T) {
+	t.Parallel()
+
+	l, err := lockfileFromDir("./testdata/lockfile/npm-v3")
+	if err != nil {
+		t.Fatalf("lockfileFromDir: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		bundle   []string
+		expected map[string]bool
+	}{
+		{
+			name:     "nothing bundled",
+			expected: map[string]bool{},
+		},
+		{
+			name:   "nested dependency",
+			bundle: []string{"a"},
+			expected: map[string]bool{
+				"node_modules/a":                true,
+				"node_modules/a/node_modules/b": true,
+			},
+		},
+		{
+			name:   "hoisted dependency",
+			bundle: []string{"@scope/c"},
+			expected: map[string]bool{
+				"node_modules/@scope/c": true,
+				"node_modules/b":        true,
+			},
+		},
+		{
+			name:   "workspace link",
+			bundle: []string{"e"},
+			expected: map[string]bool{
+				"node_modules/e": true,
+				"packages/e":     true,
+				"node_modules/b": true,
+			},
+		},
+		{
+			name:     "unknown dependency",
+			bundle:   []string{"f"},
+			expected: map[string]bool{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, l.bundled(tt.bundle)); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_Lockfile_materials_installScripts(t *testing.T) {
+	t.Parallel()
+
+	l, err := lockfileFromDir("./testdata/lockfile/install-scripts")
+	if err != nil {
+		t.Fatalf("lockfileFromDir: %v", err)
+	}
+
+	materials, err := l.materials(nil)
+	if err != nil {
+		t.Fatalf("materials: %v", err)
+	}
+
+	annotated := make(map[string]bool)
+	for _, m := range materials {
+		if _, exists := m.Annotations["hasInstallScript"]; exists {
+			annotated[m.Name] = true
+		}
+	}
+
+	expected := map[string]bool{"@scope/native": true, "esbuild": true}
+	if diff := cmp.Diff(expected, annotated); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func Test_Lockfile_materials_git(t *testing.T) {
+	t.Parallel()
+
+	l, err := lockfileFromDir("./testdata/lockfile/git")
+	if err != nil {
+		t.Fatalf("lockfileFromDir: %v", err)
+	}
+
+	materials, err := l.materials(nil)
+	if err != nil {
+		t.Fatalf("materials: %v", err)
+	}
+
+	expected := []ResourceDescriptor{
+		{
+			URI:         "git+ssh://git@github.com/org/a.git@4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+			Digest:      slsa.DigestSet{"sha1": "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0"},
+			Name:        "a",
+			Annotations: map[string]interface{}{"version": "1.0.0", "bundled": false, "dev": false},
+		},
+		{
+			URI: "https://example.com/b-2.0.0.tgz",
+			Digest: slsa.DigestSet{
+				"sha512": "06835a7792ff028890e5a3a30e7500634af20b137ccce8ba612b941c432156fbd8a3be430975df70f566e7ecc7e12e4e2e14c14b2ea9ce5ee744f89fe4727ece",
+			},
+			Name:        "b",
+			Annotations: map[string]interface{}{"version": "2.0.0", "bundled": false, "dev": false},
+		},
+	}
+	if diff := cmp.Diff(expected, materials); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func Test_Lockfile_materials_dev(t *testing.T) {
+	t.Parallel()
+
+	integrity := "sha512-BoNad5L/AoiQ5aOjDnUAY0ryCxN8zOi6YSuUHEMhVvvYo75DCXXfcPVm5+zH4S5OLhTBSy6pzl7nRPif5HJ+zg=="
+	tests := []struct {
+		name     string
+		dev      []bool
+		expected bool
+	}{
+		{
+			name:     "runtime dependency",
+			dev:      []bool{false},
+			expected: false,
+		},
+		{
+			name:     "dev dependency",
+			dev:      []bool{true, true},
+			expected: true,
+		},
+		{
+			name:     "dev and runtime dependency",
+			dev:      []bool{true, false},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The same package is installed at several paths.
+			l := &Lockfile{Packages: make(map[string]*LockedPackage)}
+			for i, dev := range tt.dev {
+				key := strings.Repeat("node_modules/c/", i) + "node_modules/a"
+				l.Packages[key] = &LockedPackage{
+					Name: "a", Version: "1.0.0",
+					Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Integrity: integrity,
+					Dev:       dev,
+				}
+			}
+
+			materials, err := l.materials(nil)
+			if err != nil {
+				t.Fatalf("materials: %v", err)
+			}
+			if len(materials) != 1 {
+				t.Fatalf("materials: %v", materials)
+			}
+			if dev := materials[0].Annotations["dev"]; dev != tt.expected {
+				t.Errorf("dev: %v, expected %v", dev, tt.expected)
+			}
+		})
+	}
+}
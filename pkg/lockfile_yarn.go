@@ -0,0 +1,250 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yarnLockfileFromString parses a yarn.lock file written by Yarn v1,
+// or by Yarn Berry (v2 and later), which uses YAML.
+func yarnLockfileFromString(b []byte) (*Lockfile, error) {
+	if bytes.Contains(b, []byte("\n__metadata:")) || bytes.HasPrefix(b, []byte("__metadata:")) {
+		return berryLockfileFromString(b)
+	}
+	return yarnV1LockfileFromString(b)
+}
+
+// yarnV1Entry is an entry of a Yarn v1 lockfile. An entry is shared
+// by all the descriptors that resolve to the same package.
+type yarnV1Entry struct {
+	descriptors []string
+	fields      map[string]string
+	deps        map[string]string
+}
+
+// yarnV1LockfileFromString parses the custom format of Yarn v1:
+//
+//	"@scope/a@^1.0.0", "@scope/a@^1.1.0":
+//	  version "1.2.0"
+//	  resolved "https://registry.yarnpkg.com/@scope/a/-/a-1.2.0.tgz#<sha1>"
+//	  integrity sha512-...
+//	  dependencies:
+//	    b "^2.0.0"
+func yarnV1LockfileFromString(b []byte) (*Lockfile, error) {
+	var entries []*yarnV1Entry
+	var entry *yarnV1Entry
+	var section string
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("%w: line %d: expected an entry", errorInvalidLockfile, n)
+			}
+			entry = &yarnV1Entry{
+				fields: make(map[string]string),
+				deps:   make(map[string]string),
+			}
+			for _, d := range strings.Split(strings.TrimSuffix(trimmed, ":"), ",") {
+				entry.descriptors = append(entry.descriptors, yarnUnquote(strings.TrimSpace(d)))
+			}
+			entries = append(entries, entry)
+			section = ""
+		case entry == nil:
+			return nil, fmt.Errorf("%w: line %d: field outside of an entry", errorInvalidLockfile, n)
+		case indent == 2 && strings.HasSuffix(trimmed, ":"):
+			section = strings.TrimSuffix(trimmed, ":")
+		case indent == 2:
+			key, value := yarnSplitField(trimmed)
+			entry.fields[key] = value
+			section = ""
+		case section == "dependencies" || section == "optionalDependencies":
+			name, r := yarnSplitField(trimmed)
+			entry.deps[name] = r
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	l := &Lockfile{
+		Packages:    make(map[string]*LockedPackage),
+		descriptors: make(map[string]string),
+	}
+	deps := make(map[string]map[string]string)
+	for _, e := range entries {
+		name, _, ok := splitDescriptor(e.descriptors[0])
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid descriptor: %s", errorInvalidLockfile, e.descriptors[0])
+		}
+
+		version := e.fields["version"]
+		if version == "" {
+			return nil, fmt.Errorf("%w: %s: missing version", errorInvalidLockfile, e.descriptors[0])
+		}
+
+		key := name + "@" + version
+		resolved, integrity := yarnV1Resolved(e.fields["resolved"], e.fields["integrity"])
+		l.Packages[key] = &LockedPackage{
+			Name:      name,
+			Version:   version,
+			Resolved:  resolved,
+			Integrity: integrity,
+		}
+		deps[key] = e.deps
+		for _, d := range e.descriptors {
+			l.descriptors[d] = key
+		}
+	}
+
+	l.resolveDependencies(deps)
+	return l, nil
+}
+
+// yarnV1Resolved returns the URL of a package and its integrity. Old
+// lockfiles have no integrity, but the URL has the sha1 of the tarball
+// as its fragment.
+func yarnV1Resolved(resolved, integrity string) (string, string) {
+	// The fragment of git dependencies is the commit.
+	if isGitResolved(resolved) {
+		return resolved, integrity
+	}
+	parts := strings.SplitN(resolved, "#", 2)
+	if len(parts) != 2 {
+		return resolved, integrity
+	}
+
+	if integrity == "" {
+		if b, err := hex.DecodeString(parts[1]); err == nil && len(b) == digestSizes["sha1"] {
+			integrity = "sha1-" + base64.StdEncoding.EncodeToString(b)
+		}
+	}
+	return parts[0], integrity
+}
+
+func yarnSplitField(s string) (string, string) {
+	parts := strings.SplitN(s, " ", 2)
+	if len(parts) != 2 {
+		return yarnUnquote(parts[0]), ""
+	}
+	return yarnUnquote(parts[0]), yarnUnquote(strings.TrimSpace(parts[1]))
+}
+
+func yarnUnquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// berryPackage is an entry of a Yarn Berry lockfile. Its checksum is
+// the digest of the archive in the Yarn cache rather than of the
+// tarball, so packages have no integrity.
+type berryPackage struct {
+	Version      string            `yaml:"version"`
+	Resolution   string            `yaml:"resolution"`
+	Dependencies map[string]string `yaml:"dependencies"`
+}
+
+func berryLockfileFromString(b []byte) (*Lockfile, error) {
+	var lf map[string]berryPackage
+	if err := yaml.Unmarshal(b, &lf); err != nil {
+		return nil, fmt.Errorf("%w: yaml.Unmarshal: %v", errorInvalidLockfile, err)
+	}
+
+	l := &Lockfile{
+		Packages:    make(map[string]*LockedPackage),
+		descriptors: make(map[string]string),
+	}
+	deps := make(map[string]map[string]string)
+	var root string
+	for descriptors, p := range lf {
+		if descriptors == "__metadata" {
+			continue
+		}
+
+		key := p.Resolution
+		name, reference, ok := splitDescriptor(key)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid resolution: %s", errorInvalidLockfile, key)
+		}
+
+		lp := &LockedPackage{
+			Name:    name,
+			Version: p.Version,
+		}
+		switch {
+		case strings.HasPrefix(reference, "npm:"):
+			lp.Resolved = registryTarballURL(name, strings.TrimPrefix(reference, "npm:"))
+		case strings.Contains(reference, "#commit="):
+			// Git repositories, e.g. https://github.com/org/a.git#commit=<sha>.
+			lp.Resolved = strings.Replace(reference, "#commit=", "#", 1)
+			if !isGitResolved(lp.Resolved) {
+				lp.Resolved = "git+" + lp.Resolved
+			}
+		case strings.HasPrefix(reference, "https:"), strings.HasPrefix(reference, "http:"):
+			lp.Resolved = reference
+		case reference == "workspace:.":
+			// The project itself.
+			root = key
+		}
+		// Other protocols, e.g. workspace: and patch:, refer
+		// to files of the project.
+
+		l.Packages[key] = lp
+		deps[key] = p.Dependencies
+		for _, d := range strings.Split(descriptors, ",") {
+			l.descriptors[strings.TrimSpace(d)] = key
+		}
+	}
+
+	l.resolveDependencies(deps)
+
+	if root != "" {
+		l.Dependencies = l.Packages[root].Dependencies
+		delete(l.Packages, root)
+	}
+	return l, nil
+}
+
+// resolveDependencies sets the dependencies of the packages from the
+// descriptors, indexed by the key of each package.
+func (l *Lockfile) resolveDependencies(deps map[string]map[string]string) {
+	for key, p := range l.Packages {
+		p.Dependencies = make(map[string]string)
+		for name, r := range deps[key] {
+			if k, exists := l.resolveDescriptor(name, r); exists {
+				p.Dependencies[name] = k
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_yarnV1Resolved(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		resolved          string
+		integrity         string
+		expectedResolved  string
+		expectedIntegrity string
+	}{
+		{
+			name:              "integrity",
+			resolved:          "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz#92f4c008ebdbc232b228bea7e59e5e00e71185b1",
+			integrity:         "sha512-BoNad5L/AoiQ5aOjDnUAY0ryCxN8zOi6YSuUHEMhVvvYo75DCXXfcPVm5+zH4S5OLhTBSy6pzl7nRPif5HJ+zg==",
+			expectedResolved:  "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz",
+			expectedIntegrity: "sha512-BoNad5L/AoiQ5aOjDnUAY0ryCxN8zOi6YSuUHEMhVvvYo75DCXXfcPVm5+zH4S5OLhTBSy6pzl7nRPif5HJ+zg==",
+		},
+		{
+			name:              "sha1 fragment",
+			resolved:          "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz#92f4c008ebdbc232b228bea7e59e5e00e71185b1",
+			expectedResolved:  "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz",
+			expectedIntegrity: "sha1-kvTACOvbwjKyKL6n5Z5eAOcRhbE=",
+		},
+		{
+			name:             "no fragment",
+			resolved:         "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz",
+			expectedResolved: "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz",
+		},
+		{
+			name:             "git commit fragment",
+			resolved:         "https://codeload.github.com/org/a/tar.gz/4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0#4f2ba2b9",
+			expectedResolved: "https://codeload.github.com/org/a/tar.gz/4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+		},
+		{
+			name:             "git repository",
+			resolved:         "git+https://github.com/org/a.git#4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+			expectedResolved: "git+https://github.com/org/a.git#4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resolved, integrity := yarnV1Resolved(tt.resolved, tt.integrity)
+			if diff := cmp.Diff(tt.expectedResolved, resolved); diff != "" {
+				t.Errorf(diff)
+			}
+			if diff := cmp.Diff(tt.expectedIntegrity, integrity); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_yarnV1LockfileFromString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		lockfile string
+		expected error
+	}{
+		{
+			name:     "missing version",
+			lockfile: "a@^1.0.0:\n  resolved \"https://registry.yarnpkg.com/a/-/a-1.0.0.tgz\"\n",
+			expected: errorInvalidLockfile,
+		},
+		{
+			name:     "field outside of an entry",
+			lockfile: "  version \"1.0.0\"\n",
+			expected: errorInvalidLockfile,
+		},
+		{
+			name:     "invalid entry",
+			lockfile: "a@^1.0.0\n",
+			expected: errorInvalidLockfile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := yarnV1LockfileFromString([]byte(tt.lockfile))
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
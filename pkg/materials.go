@@ -0,0 +1,130 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var errorInvalidBuilderID = errors.New("invalid builder id")
+
+// Files of the source repository that define the build. The releaser
+// config is added to this list by the builder.
+var sourceMaterialFiles = []string{
+	"package.json",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	".npmrc",
+}
+
+// sourceDigests returns the sha256 digests of the files
+// that exist, indexed by path.
+func sourceDigests(files []string) (map[string]string, error) {
+	digests := make(map[string]string)
+	for _, fn := range files {
+		digest, err := fileSHA256(fn)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		digests[fn] = digest
+	}
+	return digests, nil
+}
+
+func fileSHA256(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("io.Copy: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// gitURI returns the URI of a git repository at a ref, as recommended
+// by the SLSA spec, e.g. git+https://github.com/org/repo@refs/tags/v1.
+func gitURI(serverURL, repository, ref string) string {
+	uri := fmt.Sprintf("git+%s/%s", strings.TrimSuffix(serverURL, "/"), repository)
+	if ref == "" {
+		return uri
+	}
+	return fmt.Sprintf("%s@%s", uri, ref)
+}
+
+// sourceMaterials returns the files of the source repository
+// as materials, sorted by path. The path is recorded as
+// the fragment of the repository URI.
+func sourceMaterials(repoURI string, digests map[string]string) []ResourceDescriptor {
+	paths := make([]string, 0, len(digests))
+	for p := range digests {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var materials []ResourceDescriptor
+	for _, p := range paths {
+		materials = append(materials, ResourceDescriptor{
+			URI: fmt.Sprintf("%s#%s", repoURI, strings.TrimPrefix(p, "./")),
+			Digest: slsa.DigestSet{
+				"sha256": digests[p],
+			},
+		})
+	}
+	return materials
+}
+
+// builderMaterial returns the repository of the reusable workflow at
+// the commit it resolved to. builderID is the job_workflow_ref of the
+// reusable workflow, e.g. org/repo/.github/workflows/builder.yml@refs/heads/main.
+func builderMaterial(serverURL, builderID, commit string) (*ResourceDescriptor, error) {
+	parts := strings.SplitN(builderID, "@", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: %s", errorInvalidBuilderID, builderID)
+	}
+
+	path := strings.Split(parts[0], "/")
+	if len(path) < 2 {
+		return nil, fmt.Errorf("%w: %s", errorInvalidBuilderID, builderID)
+	}
+
+	if _, err := hex.DecodeString(commit); err != nil || len(commit) != 40 {
+		return nil, fmt.Errorf("builder commit is not a valid sha1: %s", commit)
+	}
+
+	return &ResourceDescriptor{
+		URI: gitURI(serverURL, strings.Join(path[:2], "/"), parts[1]),
+		Digest: slsa.DigestSet{
+			"sha1": commit,
+		},
+	}, nil
+}
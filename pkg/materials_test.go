@@ -0,0 +1,152 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+func Test_gitURI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		serverURL  string
+		repository string
+		ref        string
+		expected   string
+	}{
+		{
+			name:       "tag",
+			serverURL:  "https://github.com",
+			repository: "org/repo",
+			ref:        "refs/tags/v1.2.3",
+			expected:   "git+https://github.com/org/repo@refs/tags/v1.2.3",
+		},
+		{
+			name:       "trailing slash",
+			serverURL:  "https://github.com/",
+			repository: "org/repo",
+			ref:        "refs/heads/main",
+			expected:   "git+https://github.com/org/repo@refs/heads/main",
+		},
+		{
+			name:       "no ref",
+			serverURL:  "https://github.com",
+			repository: "org/repo",
+			expected:   "git+https://github.com/org/repo",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := gitURI(tt.serverURL, tt.repository, tt.ref)
+			if !cmp.Equal(r, tt.expected) {
+				t.Errorf(cmp.Diff(r, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_builderMaterial(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		builderID string
+		commit    string
+		expected  struct {
+			err      error
+			material *ResourceDescriptor
+		}
+	}{
+		{
+			name:      "valid builder",
+			builderID: "bcoe/slsa-github-generator-node/.github/workflows/builder.yml@refs/heads/main",
+			commit:    "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+			expected: struct {
+				err      error
+				material *ResourceDescriptor
+			}{
+				material: &ResourceDescriptor{
+					URI: "git+https://github.com/bcoe/slsa-github-generator-node@refs/heads/main",
+					Digest: slsa.DigestSet{
+						"sha1": "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+					},
+				},
+			},
+		},
+		{
+			name:      "missing ref",
+			builderID: "bcoe/slsa-github-generator-node/.github/workflows/builder.yml",
+			commit:    "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0",
+			expected: struct {
+				err      error
+				material *ResourceDescriptor
+			}{
+				err: errorInvalidBuilderID,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := builderMaterial("https://github.com", tt.builderID, tt.commit)
+			if !errCmp(err, tt.expected.err) {
+				t.Errorf(cmp.Diff(err, tt.expected.err))
+			}
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(m, tt.expected.material) {
+				t.Errorf(cmp.Diff(m, tt.expected.material))
+			}
+		})
+	}
+}
+
+func Test_sourceDigests(t *testing.T) {
+	t.Parallel()
+
+	digests, err := sourceDigests([]string{"./testdata/pkg-json-valid.json", "./testdata/does-not-exist.json"})
+	if err != nil {
+		t.Fatalf("sourceDigests: %v", err)
+	}
+
+	digest, err := fileSHA256("./testdata/pkg-json-valid.json")
+	if err != nil {
+		t.Fatalf("fileSHA256: %v", err)
+	}
+	expected := map[string]string{
+		"./testdata/pkg-json-valid.json": digest,
+	}
+
+	if !cmp.Equal(digests, expected) {
+		t.Errorf(cmp.Diff(digests, expected))
+	}
+
+	materials := sourceMaterials("git+https://github.com/org/repo@refs/tags/v1", digests)
+	if len(materials) != 1 || materials[0].URI != "git+https://github.com/org/repo@refs/tags/v1#testdata/pkg-json-valid.json" {
+		t.Errorf("unexpected materials: %v", materials)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// normalizedModTime is the modification time of the entries of a
+// normalized tarball. It is the time npm itself sets on the files.
+var normalizedModTime = time.Date(1985, time.October, 26, 8, 15, 0, 0, time.UTC)
+
+// PackConfig configures the tarball of the package.
+// It is defined in the releaser config.
+type PackConfig struct {
+	// Normalize rewrites the tarball written by npm pack so that
+	// it only depends on the content of its files.
+	Normalize bool `yaml:"normalize"`
+}
+
+// PackResult describes the tarball of the package,
+// recorded by the builder.
+type PackResult struct {
+	Normalized bool `json:"normalized"`
+	// GoVersion is the version of Go the builder was built with, if
+	// the tarball was normalized: the gzip stream depends on its
+	// compress/flate package.
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+type tarballEntry struct {
+	hdr     *tar.Header
+	content []byte
+}
+
+// normalizeTarball rewrites the tarball fn with its entries sorted by
+// name, a fixed modification time, no owner, normalized modes and a
+// gzip header without name nor time. The content and the layout of
+// the entries are unchanged, so npm reads the tarball as before.
+// The tarball must have been inspected first: its entries are read
+// into memory, up to the size limit of the policy.
+func normalizeTarball(fn string, policy *ContentPolicy) error {
+	entries, err := readTarballEntries(fn, policy.maxSize())
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].hdr.Name < entries[j].hdr.Name
+	})
+
+	tmp, err := os.CreateTemp(filepath.Dir(fn), ".normalize-*")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeNormalizedTarball(tmp, entries); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("os.File.Close: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
+
+func readTarballEntries(fn string, maxSize int64) ([]tarballEntry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: gzip.NewReader: %v", errorInvalidTarball, err)
+	}
+	defer gz.Close()
+
+	var entries []tarballEntry
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errorInvalidTarball, err)
+		}
+
+		total += hdr.Size
+		if total > maxSize {
+			return nil, fmt.Errorf("%w: the files exceed %d bytes", errorTarballTooLarge, maxSize)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errorInvalidTarball, hdr.Name, err)
+		}
+		entries = append(entries, tarballEntry{hdr: hdr, content: content})
+	}
+	return entries, nil
+}
+
+func writeNormalizedTarball(w io.Writer, entries []tarballEntry) error {
+	// The gzip header has no name and no modification time, and
+	// the OS is unknown, the default of Go. The compressed stream
+	// depends on the compress/flate package of the version of Go,
+	// recorded in PackResult.
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("gzip.NewWriterLevel: %w", err)
+	}
+
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := normalizedHeader(e.hdr)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("tar.Writer.WriteHeader: %w", err)
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			return fmt.Errorf("tar.Writer.Write: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("tar.Writer.Close: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("gzip.Writer.Close: %w", err)
+	}
+	return nil
+}
+
+// normalizedHeader returns the header of an entry with only the
+// fields that describe its content. Directories and executable
+// files have mode 0755, other entries 0644.
+func normalizedHeader(hdr *tar.Header) *tar.Header {
+	mode := int64(0o644)
+	if hdr.Typeflag == tar.TypeDir || hdr.Mode&0o111 != 0 {
+		mode = 0o755
+	}
+
+	res := &tar.Header{
+		Typeflag: hdr.Typeflag,
+		Name:     hdr.Name,
+		Linkname: hdr.Linkname,
+		Size:     hdr.Size,
+		Mode:     mode,
+		ModTime:  normalizedModTime,
+		Devmajor: hdr.Devmajor,
+		Devminor: hdr.Devminor,
+	}
+	if res.Typeflag == tar.TypeRegA {
+		res.Typeflag = tar.TypeReg
+	}
+	return res
+}
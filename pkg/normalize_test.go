@@ -0,0 +1,185 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writePackedTarball writes a tarball with the headers and contents
+// to fn, with gzip header as npm pack would set it.
+func writePackedTarball(t *testing.T, fn string, hdrs []*tar.Header, contents []string, gzHdr gzip.Header) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Header = gzHdr
+	tw := tar.NewWriter(gz)
+	for i, hdr := range hdrs {
+		hdr.Size = int64(len(contents[i]))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar.Writer.WriteHeader: %v", err)
+		}
+		if _, err := tw.Write([]byte(contents[i])); err != nil {
+			t.Fatalf("tar.Writer.Write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar.Writer.Close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip.Writer.Close: %v", err)
+	}
+	if err := os.WriteFile(fn, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+}
+
+func Test_normalizeTarball(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Now()
+
+	// The same files, packed on two machines.
+	first := filepath.Join(dir, "first.tgz")
+	writePackedTarball(t, first, []*tar.Header{
+		{Name: "package/package.json", Mode: 0o664, ModTime: now, Uid: 1001, Gid: 1001, Uname: "runner"},
+		{Name: "package/bin/cli.js", Mode: 0o775, ModTime: now, Uid: 1001, Gid: 1001, Uname: "runner"},
+		{Name: "package/lib/index.js", Mode: 0o600, ModTime: now, Uid: 1001, Gid: 1001, Uname: "runner"},
+	}, []string{`{"name":"test"}`, "#!/usr/bin/env node\n", "module.exports = 1;\n"},
+		gzip.Header{Name: "first.tar", ModTime: now})
+
+	second := filepath.Join(dir, "second.tgz")
+	writePackedTarball(t, second, []*tar.Header{
+		{Name: "package/lib/index.js", Mode: 0o644, ModTime: normalizedModTime},
+		{Name: "package/bin/cli.js", Mode: 0o755, ModTime: normalizedModTime},
+		{Name: "package/package.json", Mode: 0o644, ModTime: normalizedModTime},
+	}, []string{"module.exports = 1;\n", "#!/usr/bin/env node\n", `{"name":"test"}`},
+		gzip.Header{})
+
+	for _, fn := range []string{first, second} {
+		if err := normalizeTarball(fn, &ContentPolicy{}); err != nil {
+			t.Fatalf("normalizeTarball: %v", err)
+		}
+	}
+
+	b1, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	b2, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if !bytes.Equal(b1, b2) {
+		t.Errorf("normalized tarballs differ")
+	}
+
+	// Normalizing is idempotent.
+	if err := normalizeTarball(first, &ContentPolicy{}); err != nil {
+		t.Fatalf("normalizeTarball: %v", err)
+	}
+	b3, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if !bytes.Equal(b1, b3) {
+		t.Errorf("tarball changed when normalized twice")
+	}
+
+	entries, err := readTarballEntries(first, defaultMaxSize)
+	if err != nil {
+		t.Fatalf("readTarballEntries: %v", err)
+	}
+	type entry struct {
+		Name    string
+		Mode    int64
+		ModTime time.Time
+		Uid     int
+		Uname   string
+		Content string
+	}
+	var got []entry
+	for _, e := range entries {
+		got = append(got, entry{
+			Name:    e.hdr.Name,
+			Mode:    e.hdr.Mode,
+			ModTime: e.hdr.ModTime.UTC(),
+			Uid:     e.hdr.Uid,
+			Uname:   e.hdr.Uname,
+			Content: string(e.content),
+		})
+	}
+	expected := []entry{
+		{Name: "package/bin/cli.js", Mode: 0o755, ModTime: normalizedModTime, Content: "#!/usr/bin/env node\n"},
+		{Name: "package/lib/index.js", Mode: 0o644, ModTime: normalizedModTime, Content: "module.exports = 1;\n"},
+		{Name: "package/package.json", Mode: 0o644, ModTime: normalizedModTime, Content: `{"name":"test"}`},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error(diff)
+	}
+
+	// The gzip header has no name nor time.
+	f, err := os.Open(first)
+	if err != nil {
+		t.Fatalf("os.Open: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if gz.Name != "" || !gz.ModTime.IsZero() {
+		t.Errorf("gzip header: name %q, time %v", gz.Name, gz.ModTime)
+	}
+}
+
+func Test_normalizeTarball_invalid(t *testing.T) {
+	t.Parallel()
+
+	fn := filepath.Join(t.TempDir(), "test-1.0.1.tgz")
+	if err := os.WriteFile(fn, []byte("not a tarball"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile: %v", err)
+	}
+
+	err := normalizeTarball(fn, &ContentPolicy{})
+	if !errCmp(err, errorInvalidTarball) {
+		t.Error(cmp.Diff(err, errorInvalidTarball))
+	}
+}
+
+func Test_normalizeTarball_tooLarge(t *testing.T) {
+	t.Parallel()
+
+	fn := filepath.Join(t.TempDir(), "test-1.0.1.tgz")
+	writePackedTarball(t, fn, []*tar.Header{
+		{Name: "package/package.json", Mode: 0o644},
+		{Name: "package/index.js", Mode: 0o644},
+	}, []string{`{"name":"test"}`, "module.exports = 1;\n"}, gzip.Header{})
+
+	err := normalizeTarball(fn, &ContentPolicy{MaxSize: 20})
+	if !errCmp(err, errorTarballTooLarge) {
+		t.Error(cmp.Diff(err, errorTarballTooLarge))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	errorInvalidNpmrc       = errors.New("invalid .npmrc")
+	errorNpmrcKeyNotAllowed = errors.New(".npmrc setting not allowed")
+	errorNpmrcCredential    = errors.New(".npmrc contains a credential")
+)
+
+// npmrcDisallowedKeys are the settings of .npmrc that run arbitrary
+// commands or code, or load other configuration files.
+var npmrcDisallowedKeys = map[string]bool{
+	"script-shell":  true,
+	"node-options":  true,
+	"init-module":   true,
+	"onload-script": true,
+	"shell":         true,
+	"git":           true,
+	"userconfig":    true,
+	"globalconfig":  true,
+}
+
+// npmrcCredentialKeys are the settings of .npmrc that are credentials,
+// possibly scoped to a registry, e.g. //registry.npmjs.org/:_authToken.
+// They must reference an environment variable rather than be committed.
+var npmrcCredentialKeys = map[string]bool{
+	"_authToken": true,
+	"_auth":      true,
+	"_password":  true,
+	"cert":       true,
+	"key":        true,
+}
+
+// npmrcPersonalKeys are the settings of .npmrc
+// that identify a person. They are not recorded.
+var npmrcPersonalKeys = map[string]bool{
+	"username": true,
+	"email":    true,
+}
+
+// npmrcEnvReference matches values that are only a reference
+// to an environment variable, e.g. ${NPM_TOKEN}.
+var npmrcEnvReference = regexp.MustCompile(`^\$\{[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// npmrcSettings parses the .npmrc file of the project in dir, and returns
+// the settings to record in the provenance: credentials and personal
+// settings are stripped. It fails if the file sets a setting that is not
+// allowed or commits a credential. It returns nil if the file does not exist.
+func npmrcSettings(dir string) (map[string]string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ".npmrc"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	return npmrcFromString(b)
+}
+
+func npmrcFromString(b []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("%w: line %d: sections are not supported", errorInvalidNpmrc, n)
+		}
+
+		key, value := line, "true"
+		if i := strings.Index(line, "="); i >= 0 {
+			key, value = strings.TrimSpace(line[:i]), npmrcUnquote(strings.TrimSpace(line[i+1:]))
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%w: line %d: empty key", errorInvalidNpmrc, n)
+		}
+
+		name := npmrcSettingName(key)
+		switch {
+		case npmrcDisallowedKeys[strings.ToLower(name)]:
+			return nil, fmt.Errorf("%w: %s", errorNpmrcKeyNotAllowed, key)
+		case npmrcCredentialKeys[name]:
+			if !npmrcEnvReference.MatchString(value) {
+				return nil, fmt.Errorf("%w: %s must reference an environment variable, e.g. ${NPM_TOKEN}", errorNpmrcCredential, key)
+			}
+			continue
+		case npmrcPersonalKeys[name]:
+			continue
+		}
+
+		// Arrays, e.g. ca[]=..., are recorded as a list.
+		if strings.HasSuffix(key, "[]") {
+			key = strings.TrimSuffix(key, "[]")
+			if v, exists := settings[key]; exists {
+				value = v + "," + value
+			}
+		}
+		settings[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errorInvalidNpmrc, err)
+	}
+	return settings, nil
+}
+
+// npmrcSettingName returns the name of a setting without the
+// registry it is scoped to, e.g. _authToken for
+// //registry.npmjs.org/:_authToken.
+func npmrcSettingName(key string) string {
+	if strings.HasPrefix(key, "//") {
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			return key[i+1:]
+		}
+	}
+	return strings.TrimSuffix(key, "[]")
+}
+
+func npmrcUnquote(s string) string {
+	if strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") && len(s) >= 2 {
+		return s[1 : len(s)-1]
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
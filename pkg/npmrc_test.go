@@ -0,0 +1,141 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_npmrcFromString(t *testing.T) {
+	t.Parallel()
+
+	type result struct {
+		settings map[string]string
+		err      error
+	}
+
+	tests := []struct {
+		name     string
+		npmrc    string
+		expected result
+	}{
+		{
+			name: "settings",
+			npmrc: `# Comment
+; Comment
+registry=https://registry.npmjs.org/
+@my-org:registry = "https://npm.pkg.github.com/"
+engine-strict
+ignore-scripts=false
+ca[]=cert1
+ca[]=cert2
+`,
+			expected: result{
+				settings: map[string]string{
+					"registry":         "https://registry.npmjs.org/",
+					"@my-org:registry": "https://npm.pkg.github.com/",
+					"engine-strict":    "true",
+					"ignore-scripts":   "false",
+					"ca":               "cert1,cert2",
+				},
+			},
+		},
+		{
+			name: "credentials from the environment",
+			npmrc: `//npm.pkg.github.com/:_authToken=${NODE_AUTH_TOKEN}
+_auth=${NPM_AUTH}
+email=me@example.com
+registry=https://npm.pkg.github.com/
+`,
+			expected: result{
+				settings: map[string]string{
+					"registry": "https://npm.pkg.github.com/",
+				},
+			},
+		},
+		{
+			name:     "empty",
+			expected: result{settings: map[string]string{}},
+		},
+		{
+			name:     "committed token",
+			npmrc:    "//registry.npmjs.org/:_authToken=npm_0123456789abcdef\n",
+			expected: result{err: errorNpmrcCredential},
+		},
+		{
+			name:     "token in an environment variable with a prefix",
+			npmrc:    "//registry.npmjs.org/:_authToken=npm_${TOKEN}\n",
+			expected: result{err: errorNpmrcCredential},
+		},
+		{
+			name:     "committed password",
+			npmrc:    "_password=c2VjcmV0\n",
+			expected: result{err: errorNpmrcCredential},
+		},
+		{
+			name:     "script shell",
+			npmrc:    "script-shell=/bin/evil\n",
+			expected: result{err: errorNpmrcKeyNotAllowed},
+		},
+		{
+			name:     "node options",
+			npmrc:    "node-options=--require ./evil.js\n",
+			expected: result{err: errorNpmrcKeyNotAllowed},
+		},
+		{
+			name:     "init module",
+			npmrc:    "init-module=./evil.js\n",
+			expected: result{err: errorNpmrcKeyNotAllowed},
+		},
+		{
+			name:     "section",
+			npmrc:    "[section]\nregistry=https://registry.npmjs.org/\n",
+			expected: result{err: errorInvalidNpmrc},
+		},
+		{
+			name:     "empty key",
+			npmrc:    "=value\n",
+			expected: result{err: errorInvalidNpmrc},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			settings, err := npmrcFromString([]byte(tt.npmrc))
+			if !errCmp(err, tt.expected.err) {
+				t.Fatalf(cmp.Diff(err, tt.expected.err))
+			}
+			if diff := cmp.Diff(tt.expected.settings, settings); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_npmrcSettings_missing(t *testing.T) {
+	t.Parallel()
+
+	settings, err := npmrcSettings("./testdata")
+	if err != nil {
+		t.Fatalf("npmrcSettings: %v", err)
+	}
+	if settings != nil {
+		t.Errorf("expected no settings, got %v", settings)
+	}
+}
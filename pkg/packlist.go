@@ -0,0 +1,152 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+var (
+	errorPacklistMismatch  = errors.New("tarball does not match the packed file list")
+	errorInvalidPackOutput = errors.New("invalid output of npm pack")
+)
+
+// Packlist is the list of the files npm packs, computed before the build.
+type Packlist struct {
+	// Files are the paths of the files, relative to the root of the package.
+	Files []string `json:"files"`
+	// Size is the total size of the files, in bytes.
+	Size int64 `json:"size"`
+}
+
+// npmPackOutput is an entry of the output of npm pack --json.
+type npmPackOutput struct {
+	UnpackedSize int64 `json:"unpackedSize"`
+	Files        []struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	} `json:"files"`
+}
+
+// packlist returns the files npm packs for the project in dir, as
+// listed by npm pack --dry-run --json with the flags of the pack
+// command. Scripts do not run, so the files they create are not
+// listed; see verify.
+func packlist(dir, node, npm string, flags []string) (*Packlist, error) {
+	args := append([]string{npm, "pack"}, flags...)
+	args = append(args, "--dry-run", "--json", "--ignore-scripts")
+	cmd := exec.Command(node, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("npm pack --dry-run: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return packlistFromJSON(out)
+}
+
+// packlistFromJSON returns the files listed in the output of
+// npm pack --json, which packs a single package.
+func packlistFromJSON(b []byte) (*Packlist, error) {
+	var outputs []npmPackOutput
+	if err := json.Unmarshal(b, &outputs); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidPackOutput, err)
+	}
+	if len(outputs) != 1 {
+		return nil, fmt.Errorf("%w: %d packages", errorInvalidPackOutput, len(outputs))
+	}
+
+	pl := &Packlist{
+		Files: []string{},
+		Size:  outputs[0].UnpackedSize,
+	}
+	for _, f := range outputs[0].Files {
+		pl.Files = append(pl.Files, f.Path)
+	}
+	sort.Strings(pl.Files)
+	return pl, nil
+}
+
+// verify verifies that the files of the tarball are the files
+// of the packlist. Bundled dependencies and files matching the
+// generated patterns, i.e. files created by the scripts npm pack
+// runs, are not compared.
+func (pl *Packlist) verify(m *Manifest, generated []string) error {
+	skip := func(p string) bool {
+		if strings.HasPrefix(p, "node_modules/") {
+			return true
+		}
+		for _, pattern := range generated {
+			if matchContentPattern(pattern, p, false) {
+				return true
+			}
+		}
+		return false
+	}
+
+	expected := make(map[string]bool)
+	for _, f := range pl.Files {
+		if !skip(f) {
+			expected[f] = true
+		}
+	}
+
+	var unexpected []string
+	for _, f := range m.Files {
+		if skip(f.Path) {
+			continue
+		}
+		if !expected[f.Path] {
+			unexpected = append(unexpected, f.Path)
+		}
+		delete(expected, f.Path)
+	}
+
+	missing := make([]string, 0, len(expected))
+	for f := range expected {
+		missing = append(missing, f)
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+
+	var diffs []string
+	if len(unexpected) > 0 {
+		diffs = append(diffs, "unexpected "+strings.Join(unexpected, ", "))
+	}
+	if len(missing) > 0 {
+		diffs = append(diffs, "missing "+strings.Join(missing, ", "))
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%w: %s", errorPacklistMismatch, strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+func marshallPacklist(pl *Packlist) (string, error) {
+	jsonData, err := json.Marshal(pl)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(jsonData), nil
+}
@@ -0,0 +1,314 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeTestTree writes the files, indexed by path, to dir.
+// The ignore files are written by the test rather than checked
+// in, so that they do not apply to the repository.
+func writeTestTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for p, content := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+			t.Fatalf("os.MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(fn, []byte(content), 0o600); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+	}
+}
+
+func Test_packlist(t *testing.T) {
+	t.Parallel()
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	npm, err := exec.LookPath("npm")
+	if err != nil {
+		t.Skip("npm is not installed")
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name: "ignore files",
+			files: map[string]string{
+				"package.json":        `{"name":"pl","version":"1.0.0","main":"lib/index.js","bin":{"pl":"bin/cli.js"}}`,
+				".npmignore":          "test/\n*.log\n",
+				".gitignore":          "docs\n",
+				"lib/sub/.npmignore":  "!skip.log\n",
+				".DS_Store":           "",
+				"._x":                 "",
+				".a.swp":              "",
+				".env":                "",
+				".git/config":         "",
+				".npmrc":              "",
+				"CHANGELOG.md":        "",
+				"COPYING":             "",
+				"LICENSE":             "",
+				"README.md":           "",
+				"Readme.txt":          "",
+				"bin/cli.js":          "",
+				"docs/d.md":           "",
+				"lib/a.js":            "",
+				"lib/a.js.orig":       "",
+				"lib/index.js":        "",
+				"lib/sub/b.js":        "",
+				"lib/sub/skip.log":    "",
+				"node_modules/b/b.js": "",
+				"notes.txt":           "",
+				"npm-debug.log":       "",
+				"package-lock.json":   "",
+				"src/s.ts":            "",
+				"test/t.js":           "",
+				"yarn.lock":           "",
+			},
+			expected: []string{
+				".env", "CHANGELOG.md", "COPYING", "LICENSE", "README.md", "Readme.txt",
+				"bin/cli.js", "docs/d.md", "lib/a.js", "lib/index.js", "lib/sub/b.js",
+				"lib/sub/skip.log", "notes.txt", "package.json", "src/s.ts",
+			},
+		},
+		{
+			name: "files",
+			files: map[string]string{
+				"package.json":       `{"name":"pl","version":"1.0.0","main":"main.js","bin":"bin/cli.js","files":["lib","*.md","src/**/*.ts"]}`,
+				".npmignore":         "*.md\n",
+				"lib/.gitignore":     "b.js\n",
+				"lib/sub/.npmignore": "*.log\n",
+				".DS_Store":          "",
+				"CHANGELOG.md":       "",
+				"LICENSE.txt":        "",
+				"README.md":          "",
+				"bin/cli.js":         "",
+				"lib/index.js":       "",
+				"lib/sub/b.js":       "",
+				"lib/sub/c.log":      "",
+				"lib/x.orig":         "",
+				"licence":            "",
+				"main.js":            "",
+				"notes.txt":          "",
+				"src/b.ts":           "",
+				"src/c.js":           "",
+				"src/x/a.ts":         "",
+				"test/t.js":          "",
+			},
+			expected: []string{
+				"CHANGELOG.md", "LICENSE.txt", "README.md", "bin/cli.js", "lib/index.js",
+				"licence", "main.js", "package.json", "src/b.ts", "src/x/a.ts",
+			},
+		},
+		{
+			name: "anchored and negated patterns",
+			files: map[string]string{
+				"package.json": `{"name":"pl","version":"1.0.0"}`,
+				".gitignore":   "/top.js\na/**/x.js\nc/\n!c/z.js\n",
+				"a/b/x.js":     "",
+				"a/y.js":       "",
+				"c/z.js":       "",
+				"c/d/z.js":     "",
+				"top.js":       "",
+				"w.js":         "",
+			},
+			expected: []string{"a/y.js", "c/d/z.js", "c/z.js", "package.json", "w.js"},
+		},
+		{
+			name: "always included files",
+			files: map[string]string{
+				"package.json":     `{"name":"pl","version":"1.0.0","files":["lib"],"browser":"b.js"}`,
+				"AUTHORS":          "",
+				"CHANGELOG.md":     "",
+				"LICENSE.md":       "",
+				"Licence-MIT":      "",
+				"NOTICE":           "",
+				"README":           "",
+				"b.js":             "",
+				"copying.txt":      "",
+				"index.js":         "",
+				"lib/README.md":    "",
+				"lib/a.js":         "",
+				"lib/docs/LICENSE": "",
+			},
+			expected: []string{
+				"LICENSE.md", "README", "b.js", "copying.txt", "lib/README.md",
+				"lib/a.js", "lib/docs/LICENSE", "package.json",
+			},
+		},
+		{
+			name: "always included files ignored",
+			files: map[string]string{
+				"package.json": `{"name":"pl","version":"1.0.0"}`,
+				".npmignore":   "README.md\nLICENSE\npackage.json\n",
+				"LICENSE":      "",
+				"README.md":    "",
+				"index.js":     "",
+			},
+			expected: []string{"LICENSE", "README.md", "index.js", "package.json"},
+		},
+		{
+			name: "scripts do not run",
+			files: map[string]string{
+				"package.json": `{"name":"pl","version":"1.0.0","scripts":{"prepack":"echo > gen.js"}}`,
+				"index.js":     "",
+			},
+			expected: []string{"index.js", "package.json"},
+		},
+		{
+			name: "files anchored to the root",
+			files: map[string]string{
+				"package.json": `{"name":"pl","version":"1.0.0","files":["lib/"]}`,
+				"index.js":     "",
+				"lib/a.js":     "",
+				"x/lib/b.js":   "",
+			},
+			expected: []string{"lib/a.js", "package.json"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			writeTestTree(t, dir, tt.files)
+
+			pl, err := packlist(dir, node, npm, nil)
+			if err != nil {
+				t.Fatalf("packlist: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, pl.Files); diff != "" {
+				t.Error(diff)
+			}
+
+			var size int64
+			for _, f := range tt.expected {
+				size += int64(len(tt.files[f]))
+			}
+			if pl.Size != size {
+				t.Errorf("size: %d, expected %d", pl.Size, size)
+			}
+		})
+	}
+}
+
+func Test_packlistFromJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		output   string
+		packlist *Packlist
+		expected error
+	}{
+		{
+			name: "single package",
+			output: `[{"id":"@s/pl@1.0.0","filename":"s-pl-1.0.0.tgz","unpackedSize":68,` +
+				`"files":[{"path":"package.json","size":66,"mode":420},{"path":"index.js","size":2,"mode":420}]}]`,
+			packlist: &Packlist{Files: []string{"index.js", "package.json"}, Size: 68},
+		},
+		{
+			name:     "workspaces",
+			output:   `[{"id":"a@1.0.0","files":[]},{"id":"b@1.0.0","files":[]}]`,
+			expected: errorInvalidPackOutput,
+		},
+		{
+			name:     "not json",
+			output:   "npm notice",
+			expected: errorInvalidPackOutput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pl, err := packlistFromJSON([]byte(tt.output))
+			if !errCmp(err, tt.expected) {
+				t.Fatal(cmp.Diff(err, tt.expected))
+			}
+			if diff := cmp.Diff(tt.packlist, pl); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_Packlist_verify(t *testing.T) {
+	t.Parallel()
+
+	pl := &Packlist{Files: []string{"lib/index.js", "package.json"}}
+
+	tests := []struct {
+		name      string
+		files     []string
+		generated []string
+		expected  error
+	}{
+		{
+			name:  "match",
+			files: []string{"lib/index.js", "package.json"},
+		},
+		{
+			name:     "unexpected file",
+			files:    []string{"lib/index.js", "package.json", "secret.txt"},
+			expected: errorPacklistMismatch,
+		},
+		{
+			name:     "missing file",
+			files:    []string{"package.json"},
+			expected: errorPacklistMismatch,
+		},
+		{
+			name:      "generated file",
+			files:     []string{"dist/index.js", "lib/index.js", "package.json"},
+			generated: []string{"dist/"},
+		},
+		{
+			name:  "bundled dependency",
+			files: []string{"lib/index.js", "node_modules/b/index.js", "package.json"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &Manifest{Package: "pl-1.0.0.tgz"}
+			for _, f := range tt.files {
+				m.Files = append(m.Files, ManifestFile{Path: f})
+			}
+
+			err := pl.verify(m, tt.generated)
+			if !errCmp(err, tt.expected) {
+				t.Error(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
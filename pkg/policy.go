@@ -0,0 +1,226 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var (
+	errorInvalidPolicy   = errors.New("invalid release policy")
+	errorPolicyViolation = errors.New("release policy violation")
+)
+
+const (
+	tagRefPrefix = "refs/tags/"
+
+	tagFormatVersion = "version"
+	tagFormatPackage = "package"
+	tagFormatNone    = "none"
+
+	defaultTagPrefix = "v"
+)
+
+// defaultEvents are the events allowed if the policy does not list
+// any: the push of a tag, and release.
+var defaultEvents = []string{"push", "release"}
+
+// ReleasePolicy restricts the triggers for which provenance is
+// generated and signed. It is defined in the releaser config.
+type ReleasePolicy struct {
+	// Events are the allowed event names, e.g. push or release.
+	// If empty, only the push of a tag and release are allowed.
+	Events []string `yaml:"events" json:"events,omitempty"`
+	// Refs are the allowed refs, as path.Match patterns,
+	// e.g. refs/tags/v*. If empty, all refs are allowed.
+	Refs []string `yaml:"refs" json:"refs,omitempty"`
+	// TagVersion requires the ref to be a tag matching the version
+	// in package.json. Runs triggered by a tag are always compared,
+	// unless TagFormat is none.
+	TagVersion bool `yaml:"tag-version" json:"tag_version,omitempty"`
+	// TagPrefix is the prefix of the version in the tag.
+	// Defaults to "v".
+	TagPrefix *string `yaml:"tag-prefix" json:"tag_prefix,omitempty"`
+	// TagFormat is the format of the tag: version, e.g. v1.2.3, the
+	// default, package, e.g. my-package@v1.2.3 in a monorepo, or none
+	// if the tags are not versions of the package.
+	TagFormat string `yaml:"tag-format" json:"tag_format,omitempty"`
+	// PackageName and PackageVersion are the name and version in
+	// package.json. They are set by the builder, not by the config.
+	PackageName    string `yaml:"-" json:"package_name,omitempty"`
+	PackageVersion string `yaml:"-" json:"package_version,omitempty"`
+}
+
+// ReleaseTag maps the tag of a release to the
+// version of the package it publishes.
+type ReleaseTag struct {
+	Tag     string `json:"tag"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+func (p *ReleasePolicy) validate() error {
+	for _, r := range p.Refs {
+		if _, err := path.Match(r, ""); err != nil {
+			return fmt.Errorf("%w: ref pattern %q: %v", errorInvalidPolicy, r, err)
+		}
+	}
+
+	switch p.TagFormat {
+	case "", tagFormatVersion, tagFormatPackage:
+	case tagFormatNone:
+		if p.TagVersion {
+			return fmt.Errorf("%w: tag-version requires a tag format", errorInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: tag format %q", errorInvalidPolicy, p.TagFormat)
+	}
+	return nil
+}
+
+// evaluate returns an error if the workflow run is not allowed
+// to generate provenance. If the tag of the run was compared with
+// the version of the package, it returns the release it maps to.
+func (p *ReleasePolicy) evaluate(gh *gitHubContext) (*ReleaseTag, error) {
+	if err := p.evaluateEvent(gh.EventName, gh.Ref); err != nil {
+		return nil, err
+	}
+
+	if err := p.evaluateRef(gh.Ref); err != nil {
+		return nil, err
+	}
+
+	if p.checksTagVersion(gh.RefType) {
+		return p.evaluateTagVersion(gh.Ref)
+	}
+
+	return nil, nil
+}
+
+// checksTagVersion returns true if the tag of a run with the
+// ref type refType must match the version of the package.
+func (p *ReleasePolicy) checksTagVersion(refType string) bool {
+	return p.TagVersion || (refType == "tag" && p.TagFormat != tagFormatNone)
+}
+
+func (p *ReleasePolicy) tagPrefix() string {
+	if p.TagPrefix == nil {
+		return defaultTagPrefix
+	}
+	return *p.TagPrefix
+}
+
+// expectedTag returns the tag of the version of the package.
+func (p *ReleasePolicy) expectedTag() string {
+	tag := p.tagPrefix() + p.PackageVersion
+	if p.TagFormat == tagFormatPackage {
+		tag = p.PackageName + "@" + tag
+	}
+	return tag
+}
+
+func (p *ReleasePolicy) evaluateEvent(name, ref string) error {
+	events := p.Events
+	if len(events) == 0 {
+		// Pushes of a branch must be allowed explicitly.
+		if name == "push" && !strings.HasPrefix(ref, tagRefPrefix) {
+			return fmt.Errorf("%w: push of %q: only tags are allowed unless the policy lists the push event",
+				errorPolicyViolation, ref)
+		}
+		events = defaultEvents
+	}
+
+	for _, e := range events {
+		if e == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: event %q is not allowed, expected one of: %s",
+		errorPolicyViolation, name, strings.Join(events, ", "))
+}
+
+func (p *ReleasePolicy) evaluateRef(ref string) error {
+	if len(p.Refs) == 0 {
+		return nil
+	}
+
+	for _, r := range p.Refs {
+		// Patterns are validated when the config is loaded.
+		if ok, _ := path.Match(r, ref); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: ref %q does not match any of: %s",
+		errorPolicyViolation, ref, strings.Join(p.Refs, ", "))
+}
+
+func (p *ReleasePolicy) evaluateTagVersion(ref string) (*ReleaseTag, error) {
+	if !strings.HasPrefix(ref, tagRefPrefix) {
+		return nil, fmt.Errorf("%w: ref %q is not a tag", errorPolicyViolation, ref)
+	}
+
+	if p.PackageVersion == "" {
+		return nil, fmt.Errorf("%w: package.json has no version", errorPolicyViolation)
+	}
+	if p.TagFormat == tagFormatPackage && p.PackageName == "" {
+		return nil, fmt.Errorf("%w: package.json has no name", errorPolicyViolation)
+	}
+
+	tag := strings.TrimPrefix(ref, tagRefPrefix)
+	if expected := p.expectedTag(); tag != expected {
+		return nil, fmt.Errorf("%w: tag %q does not match the package.json version, expected %q",
+			errorPolicyViolation, tag, expected)
+	}
+	return &ReleaseTag{
+		Tag:     tag,
+		Package: p.PackageName,
+		Version: p.PackageVersion,
+	}, nil
+}
+
+func marshallPolicy(p *ReleasePolicy) (string, error) {
+	jsonData, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(jsonData), nil
+}
+
+func unmarshallPolicy(arg string) (*ReleasePolicy, error) {
+	res := &ReleasePolicy{}
+	if arg == "" {
+		return res, nil
+	}
+
+	cs, err := base64.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("base64.StdEncoding.DecodeString: %w", err)
+	}
+
+	if err := json.Unmarshal(cs, res); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := res.validate(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
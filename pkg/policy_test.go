@@ -0,0 +1,315 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_ReleasePolicy_evaluate(t *testing.T) {
+	t.Parallel()
+
+	noPrefix := ""
+	tagPolicy := ReleasePolicy{
+		Events:         []string{"push", "release"},
+		Refs:           []string{"refs/tags/v*"},
+		TagVersion:     true,
+		PackageVersion: "1.0.1",
+	}
+
+	tests := []struct {
+		name     string
+		policy   ReleasePolicy
+		gh       gitHubContext
+		expected error
+	}{
+		{
+			name:     "default policy push tag",
+			gh:       gitHubContext{EventName: "push", Ref: "refs/tags/v1.0.1"},
+			expected: nil,
+		},
+		{
+			name:     "default policy release",
+			gh:       gitHubContext{EventName: "release", Ref: "refs/tags/v1.0.1"},
+			expected: nil,
+		},
+		{
+			name:     "default policy push branch",
+			gh:       gitHubContext{EventName: "push", Ref: "refs/heads/main"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "default policy workflow_dispatch",
+			gh:       gitHubContext{EventName: "workflow_dispatch", Ref: "refs/tags/v1.0.1"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "default policy pull_request",
+			gh:       gitHubContext{EventName: "pull_request", Ref: "refs/pull/1/merge"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "default policy pull_request_target",
+			gh:       gitHubContext{EventName: "pull_request_target", Ref: "refs/heads/main"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name: "push branch allowed explicitly",
+			policy: ReleasePolicy{
+				Events: []string{"push"},
+			},
+			gh:       gitHubContext{EventName: "push", Ref: "refs/heads/main"},
+			expected: nil,
+		},
+		{
+			name: "pull_request allowed explicitly",
+			policy: ReleasePolicy{
+				Events: []string{"pull_request"},
+			},
+			gh:       gitHubContext{EventName: "pull_request", Ref: "refs/pull/1/merge"},
+			expected: nil,
+		},
+		{
+			name:     "tag push",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "push", Ref: "refs/tags/v1.0.1"},
+			expected: nil,
+		},
+		{
+			name:     "release",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "release", Ref: "refs/tags/v1.0.1"},
+			expected: nil,
+		},
+		{
+			name:     "event not allowed",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "workflow_dispatch", Ref: "refs/tags/v1.0.1"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "branch push",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "push", Ref: "refs/heads/main"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "tag without prefix",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "push", Ref: "refs/tags/1.0.1"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "tag mismatch",
+			policy:   tagPolicy,
+			gh:       gitHubContext{EventName: "push", Ref: "refs/tags/v1.0.2"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name: "tag version on branch",
+			policy: ReleasePolicy{
+				TagVersion:     true,
+				PackageVersion: "1.0.1",
+			},
+			gh:       gitHubContext{EventName: "push", Ref: "refs/heads/v1.0.1"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "tag run compared by default",
+			policy:   ReleasePolicy{PackageVersion: "1.0.1"},
+			gh:       gitHubContext{EventName: "push", RefType: "tag", Ref: "refs/tags/v1.0.2"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name:   "tag run not compared",
+			policy: ReleasePolicy{PackageVersion: "1.0.1", TagFormat: "none"},
+			gh:     gitHubContext{EventName: "push", RefType: "tag", Ref: "refs/tags/release-2022"},
+		},
+		{
+			name:   "tag without prefix allowed",
+			policy: ReleasePolicy{PackageVersion: "1.0.1", TagPrefix: &noPrefix},
+			gh:     gitHubContext{EventName: "push", RefType: "tag", Ref: "refs/tags/1.0.1"},
+		},
+		{
+			name: "monorepo tag",
+			policy: ReleasePolicy{
+				TagFormat:      "package",
+				PackageName:    "@my-org/pkg",
+				PackageVersion: "1.0.1",
+			},
+			gh: gitHubContext{EventName: "push", RefType: "tag", Ref: "refs/tags/@my-org/pkg@v1.0.1"},
+		},
+		{
+			name: "monorepo tag of another package",
+			policy: ReleasePolicy{
+				TagFormat:      "package",
+				PackageName:    "@my-org/pkg",
+				PackageVersion: "1.0.1",
+			},
+			gh:       gitHubContext{EventName: "push", RefType: "tag", Ref: "refs/tags/@my-org/other@v1.0.1"},
+			expected: errorPolicyViolation,
+		},
+		{
+			name: "tag version without package version",
+			policy: ReleasePolicy{
+				TagVersion: true,
+			},
+			gh:       gitHubContext{EventName: "push", Ref: "refs/tags/v"},
+			expected: errorPolicyViolation,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tt.policy.evaluate(&tt.gh)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_ReleasePolicy_evaluateTagVersion(t *testing.T) {
+	t.Parallel()
+
+	prefix := "release-"
+	tests := []struct {
+		name     string
+		policy   ReleasePolicy
+		ref      string
+		release  *ReleaseTag
+		expected error
+	}{
+		{
+			name:    "default prefix",
+			policy:  ReleasePolicy{PackageName: "pkg", PackageVersion: "1.2.3"},
+			ref:     "refs/tags/v1.2.3",
+			release: &ReleaseTag{Tag: "v1.2.3", Package: "pkg", Version: "1.2.3"},
+		},
+		{
+			name:     "version not bumped",
+			policy:   ReleasePolicy{PackageName: "pkg", PackageVersion: "1.2.3"},
+			ref:      "refs/tags/v1.2.4",
+			expected: errorPolicyViolation,
+		},
+		{
+			name:    "custom prefix",
+			policy:  ReleasePolicy{PackageName: "pkg", PackageVersion: "1.2.3", TagPrefix: &prefix},
+			ref:     "refs/tags/release-1.2.3",
+			release: &ReleaseTag{Tag: "release-1.2.3", Package: "pkg", Version: "1.2.3"},
+		},
+		{
+			name:    "package format",
+			policy:  ReleasePolicy{PackageName: "pkg", PackageVersion: "1.2.3", TagFormat: "package"},
+			ref:     "refs/tags/pkg@v1.2.3",
+			release: &ReleaseTag{Tag: "pkg@v1.2.3", Package: "pkg", Version: "1.2.3"},
+		},
+		{
+			name:     "package format without name",
+			policy:   ReleasePolicy{PackageVersion: "1.2.3", TagFormat: "package"},
+			ref:      "refs/tags/@v1.2.3",
+			expected: errorPolicyViolation,
+		},
+		{
+			name:     "branch",
+			policy:   ReleasePolicy{PackageName: "pkg", PackageVersion: "1.2.3"},
+			ref:      "refs/heads/v1.2.3",
+			expected: errorPolicyViolation,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			release, err := tt.policy.evaluateTagVersion(tt.ref)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+			if diff := cmp.Diff(tt.release, release); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_ReleasePolicy_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   ReleasePolicy
+		expected error
+	}{
+		{
+			name:   "package format",
+			policy: ReleasePolicy{TagFormat: "package"},
+		},
+		{
+			name:     "unknown format",
+			policy:   ReleasePolicy{TagFormat: "semver"},
+			expected: errorInvalidPolicy,
+		},
+		{
+			name:     "tag version without format",
+			policy:   ReleasePolicy{TagVersion: true, TagFormat: "none"},
+			expected: errorInvalidPolicy,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validate()
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_marshallPolicy(t *testing.T) {
+	t.Parallel()
+
+	prefix := ""
+	p := &ReleasePolicy{
+		Events:         []string{"push"},
+		Refs:           []string{"refs/tags/v*"},
+		TagVersion:     true,
+		TagPrefix:      &prefix,
+		TagFormat:      "package",
+		PackageName:    "pkg",
+		PackageVersion: "1.0.1",
+	}
+
+	s, err := marshallPolicy(p)
+	if err != nil {
+		t.Fatalf("marshallPolicy: %v", err)
+	}
+
+	r, err := unmarshallPolicy(s)
+	if err != nil {
+		t.Fatalf("unmarshallPolicy: %v", err)
+	}
+
+	if !cmp.Equal(r, p) {
+		t.Errorf(cmp.Diff(r, p))
+	}
+}
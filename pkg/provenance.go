@@ -18,7 +18,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -43,31 +42,42 @@ const (
 	defaultRekorAddr    = "https://rekor.sigstore.dev"
 )
 
+const (
+	PredicateVersionV02 = "v0.2"
+	PredicateVersionV1  = "v1"
+)
+
+var errorUnsupportedPredicateVersion = errors.New("predicate version not supported")
+
 // https://docs.github.com/en/actions/learn-github-actions/contexts#github-context.
 type gitHubContext struct {
-	Repository   string      `json:"repository"`
-	ActionPath   string      `json:"action_path"`
-	Workflow     string      `json:"workflow"`
-	EventName    string      `json:"event_name"`
-	EventPayload interface{} `json:"event"`
-	SHA          string      `json:"sha"`
-	RefType      string      `json:"ref_type"`
-	Ref          string      `json:"ref"`
-	BaseRef      string      `json:"base_ref"`
-	HeadRef      string      `json:"head_ref"`
-	Actor        string      `json:"actor"`
-	RunNumber    string      `json:"run_number"`
-	ServerUrl    string      `json:"server_url"`
-	RunID        string      `json:"run_id"`
-	RunAttempt   string      `json:"run_attempt"`
+	Repository   string          `json:"repository"`
+	ActionPath   string          `json:"action_path"`
+	Workflow     string          `json:"workflow"`
+	EventName    string          `json:"event_name"`
+	EventPayload json.RawMessage `json:"event"`
+	SHA          string          `json:"sha"`
+	RefType      string          `json:"ref_type"`
+	Ref          string          `json:"ref"`
+	BaseRef      string          `json:"base_ref"`
+	HeadRef      string          `json:"head_ref"`
+	Actor        string          `json:"actor"`
+	RunNumber    string          `json:"run_number"`
+	ServerUrl    string          `json:"server_url"`
+	RunID        string          `json:"run_id"`
+	RunAttempt   string          `json:"run_attempt"`
 	// TODO: try removing this token:
 	// `omitting Token from the struct causes an unexpected end of line from encoding/json`
 	Token string `json:"token,omitempty"`
 }
 
-var (
-	parametersVersion  int = 1
-	buildConfigVersion int = 1
+const (
+	// BuildTypeNode identifies provenance generated by this builder.
+	// The version of the buildType is bumped whenever the format of
+	// BuildConfig or Parameters changes; see BuildTypeSchema.
+	BuildTypeNode = "https://github.com/bcoe/slsa-github-generator-node/buildtypes/node/v1"
+
+	buildTypeVersion = 1
 )
 
 const (
@@ -84,6 +94,10 @@ type (
 	BuildConfig struct {
 		Version int    `json:"version"`
 		Steps   []Step `json:"steps"`
+		// Byproducts are the files written by the build that are not
+		// released, e.g. the SBOMs. They are only set in SLSA v0.2,
+		// which has no byproducts field.
+		Byproducts []ResourceDescriptor `json:"byproducts,omitempty"`
 	}
 
 	Parameters struct {
@@ -96,13 +110,63 @@ type (
 		HeadRef      string      `json:"head_ref"`
 		Actor        string      `json:"actor"`
 		SHA1         string      `json:"sha1"`
+		// Npmrc are the settings of the .npmrc file of the
+		// project, without credentials.
+		Npmrc map[string]string `json:"npmrc,omitempty"`
+		// Release is the version of the package the tag of
+		// the run was verified to match.
+		Release *ReleaseTag `json:"release,omitempty"`
 	}
 )
 
+// ProvenanceOptions contains optional settings for GenerateProvenance.
+type ProvenanceOptions struct {
+	// PredicateVersion is the version of the SLSA provenance
+	// predicate to generate. Defaults to PredicateVersionV02.
+	PredicateVersion string
+	// Materials are the sha256 digests of the files of the source
+	// repository that define the build, as output by `build --dry`.
+	Materials string
+	// BuilderCommit is the sha1 of the commit the reusable workflow
+	// resolved to.
+	BuilderCommit string
+	// BuildResult is the result recorded by the builder.
+	BuildResult *BuildResult
+	// EventRedaction is the redaction policy of the event payload.
+	// Defaults to RedactionMinimal.
+	EventRedaction string
+	// Policy is the release policy, as output by `build --dry`.
+	// The default policy only allows the push of a tag and release.
+	Policy string
+	// InstallCommand is the command that installs the dependencies
+	// before the build, as output by `build --dry`. It runs with
+	// the same env variables as the build command.
+	InstallCommand string
+	// Npmrc are the settings of the .npmrc file of the project,
+	// as output by `build --dry`.
+	Npmrc string
+}
+
+// provenance contains the information recorded in the provenance,
+// independently of the predicate version used to encode it.
+type provenance struct {
+	subjects     []intoto.Subject
+	builderID    string
+	configSource slsa.ConfigSource
+	environment  map[string]interface{}
+	parameters   Parameters
+	buildConfig  BuildConfig
+	materials    []ResourceDescriptor
+	// byproducts are the files written by the build next to
+	// the package, e.g. its SBOMs, which are not released.
+	byproducts []ResourceDescriptor
+	metadata   slsa.ProvenanceMetadata
+}
+
 // GenerateProvenance translates github context into a SLSA provenance
 // attestation.
-// Spec: https://slsa.dev/provenance/v0.1
-func GenerateProvenance(name, digest, ghContext, command, envs string) ([]byte, error) {
+// Spec: https://slsa.dev/provenance/v0.2 and https://slsa.dev/provenance/v1
+func GenerateProvenance(subjects []intoto.Subject, ghContext, command, envs string, opts *ProvenanceOptions) ([]byte, error) {
 	gh := &gitHubContext{}
 
 	if err := json.Unmarshal([]byte(ghContext), gh); err != nil {
@@ -111,8 +175,30 @@ func GenerateProvenance(name, digest, ghContext, command, envs string) ([]byte,
 
 	gh.Token = ""
 
-	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 64 {
-		return nil, fmt.Errorf("sha256 digest is not valid: %s", digest)
+	if opts == nil {
+		opts = &ProvenanceOptions{}
+	}
+
+	policy, err := unmarshallPolicy(opts.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never sign provenance for a trigger the policy does not allow.
+	release, err := policy.evaluate(gh)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subjects recorded by the builder are merged with
+	// the ones passed explicitly.
+	if opts.BuildResult != nil {
+		subjects = append(subjects, opts.BuildResult.Subjects...)
+	}
+
+	subjects, err = validateSubjects(subjects)
+	if err != nil {
+		return nil, err
 	}
 
 	com, err := unmarshallList(command)
@@ -125,89 +211,295 @@ func GenerateProvenance(name, digest, ghContext, command, envs string) ([]byte,
 		return nil, err
 	}
 
+	install, err := unmarshallList(opts.InstallCommand)
+	if err != nil {
+		return nil, err
+	}
+
 	builderID, err := getReusableWorkflowID()
 	if err != nil {
 		return nil, err
 	}
 
-	att := intoto.ProvenanceStatement{
-		StatementHeader: intoto.StatementHeader{
-			Type:          intoto.StatementInTotoV01,
-			PredicateType: slsa.PredicateSLSAProvenance,
-			Subject: []intoto.Subject{
-				{
-					Name: name,
-					Digest: slsa.DigestSet{
-						"sha256": digest,
-					},
-				},
+	digests, err := unmarshallMap(opts.Materials)
+	if err != nil {
+		return nil, err
+	}
+
+	npmrc, err := unmarshallMap(opts.Npmrc)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newProvenance(subjects, gh, builderID, com, env)
+	if err := p.setEventPayload(gh.EventName, gh.EventPayload, opts.EventRedaction); err != nil {
+		return nil, err
+	}
+	p.addSourceMaterials(digests)
+	if len(npmrc) > 0 {
+		p.parameters.Npmrc = npmrc
+	}
+	p.parameters.Release = release
+
+	if len(install) > 0 {
+		p.addInstallStep(install, env)
+	}
+
+	if opts.BuilderCommit != "" {
+		if err := p.addBuilderMaterial(gh.ServerUrl, builderID, opts.BuilderCommit); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.BuildResult != nil {
+		p.setBuildResult(opts.BuildResult, install)
+	}
+
+	att, err := p.statement(opts.PredicateVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	attBytes, err := json.Marshal(att)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never sign provenance that does not match the published buildType.
+	if err := validateStatement(attBytes); err != nil {
+		return nil, err
+	}
+
+	return signAttestation(attBytes)
+}
+
+func newProvenance(subjects []intoto.Subject, gh *gitHubContext, builderID string,
+	com, env []string) *provenance {
+	// An empty list is encoded as null by encoding/json.
+	if env == nil {
+		env = []string{}
+	}
+	if com == nil {
+		com = []string{}
+	}
+
+	repoURI := gitURI(gh.ServerUrl, gh.Repository, gh.Ref)
+
+	return &provenance{
+		subjects: subjects,
+		// Identifies the reusable workflow and matches the job_workflow_ref.
+		// TODO(https://github.com/slsa-framework/slsa-github-generator-go/issues/6): add
+		// version and hash.
+		builderID: fmt.Sprintf("https://github.com/%s", builderID),
+		configSource: slsa.ConfigSource{
+			EntryPoint: gh.Workflow,
+			URI:        repoURI,
+			Digest: slsa.DigestSet{
+				"sha1": gh.SHA,
 			},
 		},
-		Predicate: slsa.ProvenancePredicate{
-			// Identifies that this is a slsa-framework's slsa-github-generator-go' build.
-			BuildType: "https://github.com/slsa-framework/slsa-github-generator-go@v1",
-			// Identifies the reusable workflow and matches the job_workflow_ref.
-			Builder: slsa.ProvenanceBuilder{
-				// TODO(https://github.com/slsa-framework/slsa-github-generator-go/issues/6): add
-				// version and hash.
-				ID: fmt.Sprintf("https://github.com/%s", builderID),
-			},
-			Invocation: slsa.ProvenanceInvocation{
-				ConfigSource: slsa.ConfigSource{
-					EntryPoint: gh.Workflow,
-					URI:        fmt.Sprintf("git+%s%s@%s.git", gh.ServerUrl, gh.Repository, gh.Ref),
-					Digest: slsa.DigestSet{
-						"sha1": gh.SHA,
-					},
-				},
-				// Non user-controllable environment vars needed to reproduce the build.
-				Environment: map[string]interface{}{
-					"arch":               "amd64", // TODO: Does GitHub run actually expose this?
-					"os":                 "ubuntu",
-					"github_event_name":  gh.EventName,
-					"github_run_number":  gh.RunNumber,
-					"github_run_id":      gh.RunID,
-					"github_run_attempt": gh.RunAttempt,
-				},
-				// Parameters coming from the trigger event.
-				Parameters: Parameters{
-					Version:      parametersVersion,
-					EventName:    gh.EventName,
-					Ref:          gh.Ref,
-					BaseRef:      gh.BaseRef,
-					HeadRef:      gh.HeadRef,
-					RefType:      gh.RefType,
-					Actor:        gh.Actor,
-					SHA1:         gh.SHA,
-					EventPayload: gh.EventPayload,
+		// Non user-controllable environment vars needed to reproduce the build.
+		// The runner's environment is recorded by the builder; see setBuildResult.
+		environment: map[string]interface{}{
+			"github_event_name":  gh.EventName,
+			"github_run_number":  gh.RunNumber,
+			"github_run_id":      gh.RunID,
+			"github_run_attempt": gh.RunAttempt,
+		},
+		// Parameters coming from the trigger event.
+		parameters: Parameters{
+			Version:   buildTypeVersion,
+			EventName: gh.EventName,
+			Ref:       gh.Ref,
+			BaseRef:   gh.BaseRef,
+			HeadRef:   gh.HeadRef,
+			RefType:   gh.RefType,
+			Actor:     gh.Actor,
+			SHA1:      gh.SHA,
+		},
+		buildConfig: BuildConfig{
+			Version: buildTypeVersion,
+			Steps: []Step{
+				// Single step.
+				{
+					Command: com,
+					Env:     env,
 				},
 			},
-			BuildConfig: BuildConfig{
-				Version: buildConfigVersion,
-				Steps: []Step{
-					// Single step.
-					{
-						Command: com,
-						Env:     env,
-					},
+		},
+		materials: []ResourceDescriptor{
+			{
+				URI: repoURI,
+				Digest: slsa.DigestSet{
+					"sha1": gh.SHA,
 				},
 			},
-			Materials: []slsa.ProvenanceMaterial{
-				{
-					URI: fmt.Sprintf("git+%s.git", gh.Repository),
-					Digest: slsa.DigestSet{
-						"sha1": gh.SHA,
-					},
-				},
+		},
+		metadata: slsa.ProvenanceMetadata{
+			// Unique for each re-run of the workflow.
+			BuildInvocationID: fmt.Sprintf("%s-%s", gh.RunID, gh.RunAttempt),
+			Completeness: slsa.ProvenanceComplete{
+				// All inputs of the workflow that affect the build are recorded.
+				// The steps run with the env of the runner, which is not
+				// recorded, so the environment is never complete. The
+				// materials are only complete if the builder recorded
+				// them; see setBuildResult.
+				Parameters: true,
 			},
+			// The builder does not verify that the build is reproducible.
+			Reproducible: false,
 		},
 	}
+}
 
-	attBytes, err := json.Marshal(att)
+// setEventPayload records the redacted payload of the event
+// that triggered the workflow.
+func (p *provenance) setEventPayload(eventName string, payload json.RawMessage, policy string) error {
+	event, err := parseEventPayload(eventName, payload, policy)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	p.parameters.EventPayload = event
+	return nil
+}
+
+// setBuildResult records the information reported by the builder.
+// install is the command of the dry run that installs the dependencies.
+func (p *provenance) setBuildResult(r *BuildResult, install []string) {
+	p.metadata.BuildStartedOn = r.StartedOn
+	p.metadata.BuildFinishedOn = r.FinishedOn
+
+	if r.Environment != nil {
+		for k, v := range r.Environment.values() {
+			p.environment[k] = v
+		}
+	}
+
+	if r.InstallScripts != nil {
+		p.environment["npm_ignore_scripts"] = r.InstallScripts.IgnoreScripts
+	}
+
+	if r.Toolchain != nil {
+		p.materials = append(p.materials, r.Toolchain.material())
+	}
+
+	deps := r.Dependencies
+	if r.Install != nil {
+		p.environment["npm_offline"] = r.Install.Offline
+		deps = r.Install.withTarballDigests(deps)
+		if len(r.Install.CacheCommand) > 0 {
+			p.addCacheStep(r.Install.CacheCommand)
+		}
+	}
+	if r.Pack != nil {
+		p.environment["npm_pack_normalized"] = r.Pack.Normalized
+		if r.Pack.GoVersion != "" {
+			p.environment["npm_pack_go_version"] = r.Pack.GoVersion
+		}
+	}
+	p.materials = append(p.materials, deps...)
+
+	for _, s := range r.byproducts() {
+		p.byproducts = append(p.byproducts, ResourceDescriptor{
+			Name:   s.Name,
+			Digest: s.Digest,
+		})
+	}
+
+	p.metadata.Completeness.Materials = r.materialsComplete(install)
+}
+
+// addInstallStep records the install of the dependencies,
+// which runs before the build command.
+func (p *provenance) addInstallStep(com, env []string) {
+	p.buildConfig.Steps = append([]Step{
+		{
+			Command: com,
+			Env:     env,
+		},
+	}, p.buildConfig.Steps...)
+}
+
+// addCacheStep records the command that added the tarballs of an
+// offline install to the npm cache. It runs first, with the env of the
+// other steps.
+func (p *provenance) addCacheStep(com []string) {
+	env := []string{}
+	if len(p.buildConfig.Steps) > 0 {
+		env = p.buildConfig.Steps[0].Env
+	}
+	p.addInstallStep(com, env)
+}
+
+// addSourceMaterials records the files of the source repository
+// that define the build.
+func (p *provenance) addSourceMaterials(digests map[string]string) {
+	p.materials = append(p.materials, sourceMaterials(p.configSource.URI, digests)...)
+}
+
+// addBuilderMaterial records the repository of the reusable workflow.
+func (p *provenance) addBuilderMaterial(serverURL, builderID, commit string) error {
+	m, err := builderMaterial(serverURL, builderID, commit)
+	if err != nil {
+		return err
+	}
+	p.materials = append(p.materials, *m)
+	return nil
+}
+
+// statement encodes the provenance as an in-toto statement
+// with the requested predicate version.
+func (p *provenance) statement(predicateVersion string) (interface{}, error) {
+	switch predicateVersion {
+	case "", PredicateVersionV02:
+		return p.statementV02(), nil
+	case PredicateVersionV1:
+		return p.statementV1(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errorUnsupportedPredicateVersion, predicateVersion)
+	}
+}
+
+func (p *provenance) statementV02() *intoto.ProvenanceStatement {
+	// v0.2 materials have no name or annotations.
+	var materials []slsa.ProvenanceMaterial
+	for _, m := range p.materials {
+		materials = append(materials, slsa.ProvenanceMaterial{
+			URI:    m.URI,
+			Digest: m.Digest,
+		})
 	}
 
+	buildConfig := p.buildConfig
+	buildConfig.Byproducts = p.byproducts
+
+	return &intoto.ProvenanceStatement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: slsa.PredicateSLSAProvenance,
+			Subject:       p.subjects,
+		},
+		Predicate: slsa.ProvenancePredicate{
+			// Identifies that this is a slsa-github-generator-node build.
+			BuildType: BuildTypeNode,
+			Builder: slsa.ProvenanceBuilder{
+				ID: p.builderID,
+			},
+			Invocation: slsa.ProvenanceInvocation{
+				ConfigSource: p.configSource,
+				Environment:  p.environment,
+				Parameters:   p.parameters,
+			},
+			BuildConfig: buildConfig,
+			Metadata:    &p.metadata,
+			Materials:   materials,
+		},
+	}
+}
+
+// signAttestation signs the attestation with a Fulcio certificate and
+// uploads the signed DSSE envelope to Rekor.
+func signAttestation(attBytes []byte) ([]byte, error) {
 	// Get Fulcio signer
 	ctx := context.Background()
 	if !providers.Enabled(ctx) {
@@ -265,13 +557,35 @@ func unmarshallList(arg string) ([]string, error) {
 	return res, nil
 }
 
+func unmarshallMap(arg string) (map[string]string, error) {
+	res := make(map[string]string)
+	if arg == "" {
+		return res, nil
+	}
+
+	cs, err := base64.StdEncoding.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("base64.StdEncoding.DecodeString: %w", err)
+	}
+
+	if err := json.Unmarshal(cs, &res); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	return res, nil
+}
+
 func verifyProvenanceName(name string) error {
-	const alpha = "abcdefghijklmnopqrstuvwxyz1234567890-_"
+	const alpha = "abcdefghijklmnopqrstuvwxyz1234567890-_."
 
 	if name == "" {
 		return errors.New("empty provenance name")
 	}
 
+	// The name is used as a filename.
+	if name == "." || name == ".." {
+		return fmt.Errorf("invalid filename: %s", name)
+	}
+
 	for _, char := range name {
 		if !strings.Contains(alpha, strings.ToLower(string(char))) {
 			return fmt.Errorf("invalid filename: found character '%c' in %s", char, name)
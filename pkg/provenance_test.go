@@ -1,6 +1,9 @@
 package pkg
 
 import (
+	"encoding/json"
+	"flag"
+	"os"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -50,3 +53,138 @@ func Test_unmarshallCommand(t *testing.T) {
 		})
 	}
 }
+
+// Run `go test ./pkg -update` to regenerate golden files.
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got with the content of the golden file at path.
+// The golden file is rewritten when the -update flag is passed.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("os.WriteFile: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+
+	if !cmp.Equal(string(got), string(expected)) {
+		t.Errorf("%s is out of date, run `go test ./pkg -update`:\n%s",
+			path, cmp.Diff(string(got), string(expected)))
+	}
+}
+
+func testProvenance(t *testing.T) *provenance {
+	t.Helper()
+
+	ctx, err := os.ReadFile("./testdata/github_context_push_tag.json")
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+
+	gh := &gitHubContext{}
+	if err := json.Unmarshal(ctx, gh); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	builderID := "bcoe/slsa-github-generator-node/.github/workflows/builder.yml@refs/heads/main"
+	subjects, err := SubjectsFromChecksumsFile("./testdata/checksums-valid.txt")
+	if err != nil {
+		t.Fatalf("SubjectsFromChecksumsFile: %v", err)
+	}
+
+	p := newProvenance(subjects, gh, builderID, []string{"/usr/local/bin/node", "/usr/local/bin/npm", "pack"}, nil)
+	install := []string{
+		"/usr/local/bin/node", "/usr/local/bin/npm", "ci", "--ignore-scripts",
+		"--cache", "/tmp/slsa-github-generator-node/npm-cache", "--no-audit", "--no-fund", "--offline",
+	}
+	p.addInstallStep(install, []string{})
+	if err := p.setEventPayload(gh.EventName, gh.EventPayload, RedactionMinimal); err != nil {
+		t.Fatalf("setEventPayload: %v", err)
+	}
+	p.addSourceMaterials(map[string]string{
+		"package.json":           "1f0b2c6bd2e4b0f9e1cd5b1a1e0b4e7cb2d1e9a6a4b0ff3c9dbc8fa8ee8d5d6c",
+		"package-lock.json":      "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c",
+		".slsa-nodereleaser.yml": "7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730",
+	})
+	if err := p.addBuilderMaterial(gh.ServerUrl, builderID, "4f2ba2b9dcf5d0a8d5fb8d7d7c1ea4b2b1f8c9a0"); err != nil {
+		t.Fatalf("addBuilderMaterial: %v", err)
+	}
+	p.parameters.Npmrc = map[string]string{
+		"engine-strict":    "true",
+		"@my-org:registry": "https://npm.pkg.github.com/",
+	}
+	p.parameters.Release = &ReleaseTag{
+		Tag:     "v1.0.1",
+		Package: "slsa-github-generator-node-test",
+		Version: "1.0.1",
+	}
+
+	r, err := BuildResultFromFile("./testdata/build-result-valid.json")
+	if err != nil {
+		t.Fatalf("BuildResultFromFile: %v", err)
+	}
+	p.setBuildResult(r, install)
+	return p
+}
+
+func Test_provenanceStatement(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		predicateVersion string
+		golden           string
+		expected         error
+	}{
+		{
+			name:             "default",
+			predicateVersion: "",
+			golden:           "./testdata/provenance-v0.2.golden.json",
+		},
+		{
+			name:             "v0.2",
+			predicateVersion: PredicateVersionV02,
+			golden:           "./testdata/provenance-v0.2.golden.json",
+		},
+		{
+			name:             "v1",
+			predicateVersion: PredicateVersionV1,
+			golden:           "./testdata/provenance-v1.golden.json",
+		},
+		{
+			name:             "unsupported version",
+			predicateVersion: "v0.1",
+			expected:         errorUnsupportedPredicateVersion,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			// Not parallel: several sub-tests share the same golden file.
+			p := testProvenance(t)
+
+			att, err := p.statement(tt.predicateVersion)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+
+			b, err := json.MarshalIndent(att, "", "  ")
+			if err != nil {
+				t.Fatalf("json.MarshalIndent: %v", err)
+			}
+			if err := validateStatement(b); err != nil {
+				t.Fatalf("validateStatement: %v", err)
+			}
+			checkGolden(t, tt.golden, append(b, '\n'))
+		})
+	}
+}
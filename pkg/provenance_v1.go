@@ -0,0 +1,119 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+// PredicateSLSAProvenanceV1 is the predicate type of SLSA v1.0 provenance.
+// The in-toto library we depend on only ships v0.1 and v0.2, so the
+// v1.0 model is defined here.
+// Spec: https://slsa.dev/spec/v1.0/provenance
+const PredicateSLSAProvenanceV1 = "https://slsa.dev/provenance/v1"
+
+type (
+	ProvenanceStatementV1 struct {
+		intoto.StatementHeader
+		Predicate ProvenancePredicateV1 `json:"predicate"`
+	}
+
+	ProvenancePredicateV1 struct {
+		BuildDefinition BuildDefinitionV1 `json:"buildDefinition"`
+		RunDetails      RunDetailsV1      `json:"runDetails"`
+	}
+
+	BuildDefinitionV1 struct {
+		BuildType            string               `json:"buildType"`
+		ExternalParameters   interface{}          `json:"externalParameters"`
+		InternalParameters   interface{}          `json:"internalParameters,omitempty"`
+		ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+	}
+
+	RunDetailsV1 struct {
+		Builder    BuilderV1            `json:"builder"`
+		Metadata   *BuildMetadataV1     `json:"metadata,omitempty"`
+		Byproducts []ResourceDescriptor `json:"byproducts,omitempty"`
+	}
+
+	BuilderV1 struct {
+		ID      string            `json:"id"`
+		Version map[string]string `json:"version,omitempty"`
+	}
+
+	BuildMetadataV1 struct {
+		InvocationID string     `json:"invocationId,omitempty"`
+		StartedOn    *time.Time `json:"startedOn,omitempty"`
+		FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+	}
+
+	ResourceDescriptor struct {
+		URI         string                 `json:"uri,omitempty"`
+		Digest      slsa.DigestSet         `json:"digest,omitempty"`
+		Name        string                 `json:"name,omitempty"`
+		Annotations map[string]interface{} `json:"annotations,omitempty"`
+	}
+
+	// ExternalParametersV1 are the parameters under the control
+	// of the caller of the builder workflow.
+	ExternalParametersV1 struct {
+		Source     slsa.ConfigSource `json:"source"`
+		Parameters Parameters        `json:"parameters"`
+	}
+
+	// InternalParametersV1 are the parameters set by the builder.
+	InternalParametersV1 struct {
+		BuildConfig BuildConfig            `json:"buildConfig"`
+		Environment map[string]interface{} `json:"environment"`
+	}
+)
+
+func (p *provenance) statementV1() *ProvenanceStatementV1 {
+	return &ProvenanceStatementV1{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: PredicateSLSAProvenanceV1,
+			Subject:       p.subjects,
+		},
+		Predicate: ProvenancePredicateV1{
+			BuildDefinition: BuildDefinitionV1{
+				BuildType: BuildTypeNode,
+				ExternalParameters: ExternalParametersV1{
+					Source:     p.configSource,
+					Parameters: p.parameters,
+				},
+				InternalParameters: InternalParametersV1{
+					BuildConfig: p.buildConfig,
+					Environment: p.environment,
+				},
+				ResolvedDependencies: p.materials,
+			},
+			RunDetails: RunDetailsV1{
+				Builder: BuilderV1{
+					ID: p.builderID,
+				},
+				Metadata: &BuildMetadataV1{
+					InvocationID: p.metadata.BuildInvocationID,
+					StartedOn:    p.metadata.BuildStartedOn,
+					FinishedOn:   p.metadata.BuildFinishedOn,
+				},
+				Byproducts: p.byproducts,
+			},
+		},
+	}
+}
@@ -0,0 +1,442 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+var (
+	errorInvalidProvenance = errors.New("invalid provenance")
+	errorSourceMismatch    = errors.New("source does not match the provenance")
+	errorPublishedMismatch = errors.New("published tarball does not match the provenance")
+)
+
+// recordedBuild is the build recorded in a provenance,
+// independently of the predicate version.
+type recordedBuild struct {
+	subjects    []intoto.Subject
+	source      slsa.ConfigSource
+	buildConfig BuildConfig
+	environment map[string]interface{}
+}
+
+// RebuildResult is the outcome of the rebuild of a package.
+type RebuildResult struct {
+	// Package is the name of the tarball.
+	Package string
+	// Expected are the digests of the subject of the provenance.
+	Expected slsa.DigestSet
+	// Digest are the digests of the rebuilt tarball.
+	Digest       slsa.DigestSet
+	Reproducible bool
+	// Diff lists the differences between the files of the published
+	// tarball and of the rebuilt one, if they differ and the
+	// published tarball is known.
+	Diff []string
+}
+
+func recordedBuildFromEnvelope(b []byte) (*recordedBuild, error) {
+	var env dsse.Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidProvenance, err)
+	}
+	if env.PayloadType != intoto.PayloadType {
+		return nil, fmt.Errorf("%w: payload type %q", errorInvalidProvenance, env.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: base64.StdEncoding.DecodeString: %v", errorInvalidProvenance, err)
+	}
+	return recordedBuildFromStatement(payload)
+}
+
+func recordedBuildFromStatement(b []byte) (*recordedBuild, error) {
+	var header intoto.StatementHeader
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidProvenance, err)
+	}
+
+	rb := &recordedBuild{
+		subjects: header.Subject,
+	}
+	var buildType string
+	var buildConfig, environment interface{}
+	switch header.PredicateType {
+	case slsa.PredicateSLSAProvenance:
+		var st intoto.ProvenanceStatement
+		if err := json.Unmarshal(b, &st); err != nil {
+			return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidProvenance, err)
+		}
+		buildType = st.Predicate.BuildType
+		rb.source = st.Predicate.Invocation.ConfigSource
+		buildConfig = st.Predicate.BuildConfig
+		environment = st.Predicate.Invocation.Environment
+	case PredicateSLSAProvenanceV1:
+		var st ProvenanceStatementV1
+		if err := json.Unmarshal(b, &st); err != nil {
+			return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidProvenance, err)
+		}
+		buildType = st.Predicate.BuildDefinition.BuildType
+
+		var external ExternalParametersV1
+		if err := remarshal(st.Predicate.BuildDefinition.ExternalParameters, &external); err != nil {
+			return nil, err
+		}
+		var internal InternalParametersV1
+		if err := remarshal(st.Predicate.BuildDefinition.InternalParameters, &internal); err != nil {
+			return nil, err
+		}
+		rb.source = external.Source
+		buildConfig = internal.BuildConfig
+		environment = internal.Environment
+	default:
+		return nil, fmt.Errorf("%w: predicate type %q", errorInvalidProvenance, header.PredicateType)
+	}
+
+	if buildType != BuildTypeNode {
+		return nil, fmt.Errorf("%w: build type %q", errorInvalidProvenance, buildType)
+	}
+	if err := remarshal(buildConfig, &rb.buildConfig); err != nil {
+		return nil, err
+	}
+	if err := remarshal(environment, &rb.environment); err != nil {
+		return nil, err
+	}
+
+	for _, s := range rb.buildConfig.Steps {
+		// Steps run node with the npm script and its arguments.
+		if len(s.Command) < 3 {
+			return nil, fmt.Errorf("%w: step %q", errorInvalidProvenance, s.Command)
+		}
+	}
+	return rb, nil
+}
+
+// remarshal decodes a field of the provenance, decoded
+// as a generic value, into v.
+func remarshal(in, v interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidProvenance, err)
+	}
+	return nil
+}
+
+// subject returns the subject of the provenance named name.
+func (rb *recordedBuild) subject(name string) (*intoto.Subject, error) {
+	for i := range rb.subjects {
+		if rb.subjects[i].Name == name {
+			return &rb.subjects[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no subject %s", errorInvalidProvenance, name)
+}
+
+// flag returns the boolean recorded in the environment.
+func (rb *recordedBuild) flag(name string) bool {
+	v, ok := rb.environment[name].(bool)
+	return ok && v
+}
+
+// commands returns the commands of the steps, run with the local node,
+// npm and npm cache rather than the ones of the runner. Only the steps the
+// builder runs are replayed, so that a tampered provenance cannot run other
+// commands on the machine of the verifier: the npm cache add of an offline
+// install, the install of the dependencies and the pack of the package.
+// The npm cache add step adds the tarballs in paths, downloaded and
+// verified by the rebuild, rather than the ones of the runner.
+func (rb *recordedBuild) commands(toolchain *Toolchain, paths []string) ([][]string, error) {
+	b := &NodeBuild{
+		node: toolchain.NodePath,
+		npm:  toolchain.NpmPath,
+		cfg:  &GoReleaserConfig{},
+	}
+	steps := rb.buildConfig.Steps
+	commands := make([][]string, 0, len(steps))
+	cached := false
+	for i, s := range steps {
+		if err := validateStepEnv(s.Env); err != nil {
+			return nil, err
+		}
+
+		last := i == len(steps)-1
+		var c []string
+		switch {
+		case i == 0 && !last && rb.flag("npm_offline") && isCacheAddCommand(s.Command):
+			c = b.cacheAddCommand(paths)
+			cached = true
+		case !last && isReplayedInstallCommand(b, s.Command):
+			c = b.installCommand()
+		case last && len(s.Command) == 3 && s.Command[2] == "pack":
+			c = []string{b.node, b.npm, "pack"}
+		default:
+			return nil, fmt.Errorf("%w: unsupported step %q", errorInvalidProvenance, s.Command)
+		}
+		commands = append(commands, c)
+	}
+	if len(paths) > 0 && !cached {
+		return nil, fmt.Errorf("%w: offline install without npm cache add step", errorInvalidProvenance)
+	}
+	return commands, nil
+}
+
+// isReplayedInstallCommand returns true if com is an install command of
+// the builder, and sets the config of b so that b.installCommand returns
+// it with the local node, npm and npm cache.
+func isReplayedInstallCommand(b *NodeBuild, com []string) bool {
+	if len(com) < 6 || com[4] != "--cache" {
+		return false
+	}
+	recorded := append([]string{b.node, b.npm}, com[2:]...)
+	recorded[5] = npmCacheDir()
+
+	for _, run := range []bool{false, true} {
+		for _, offline := range []bool{false, true} {
+			b.cfg.InstallScripts.Run = run
+			b.cfg.Install.Offline = offline
+			if reflect.DeepEqual(b.installCommand(), recorded) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateStepEnv verifies that a step sets no env variables. The
+// builder records none, and the provenance is not verified: a variable
+// such as NODE_OPTIONS would run arbitrary code.
+func validateStepEnv(env []string) error {
+	if len(env) > 0 {
+		return fmt.Errorf("%w: env variables %q", errorInvalidProvenance, env)
+	}
+	return nil
+}
+
+// Rebuild replays the steps recorded in the provenance envelope
+// provenancePath in the source checked out in the current directory,
+// and compares the tarball they write with the subject of the
+// provenance. If they differ and the published tarball is set, the
+// files of both tarballs are compared. The signature of the envelope
+// is not verified: only the steps the builder runs are replayed.
+func Rebuild(provenancePath, published string) (*RebuildResult, error) {
+	b, err := os.ReadFile(provenancePath)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+	rb, err := recordedBuildFromEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgJson, err := PkgJSONFromFile("package.json")
+	if err != nil {
+		return nil, err
+	}
+
+	filename := packageFilename(pkgJson)
+
+	// Aliases of the version files resolve to the recorded version.
+	recordedVersion, _ := rb.environment["node_version"].(string)
+	toolchain, err := ResolveToolchain(pkgJson, ".", recordedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// The source must be the commit of the provenance, unmodified.
+	// The tarballs of the package may be in the working tree.
+	// The pack step has no flags; see commands.
+	pl, err := packlist(".", toolchain.NodePath, toolchain.NpmPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	exempt := map[string]bool{
+		filename: true,
+		filepath.ToSlash(filepath.Clean(published)): true,
+	}
+	if err := verifyCheckout(".", rb.source.Digest["sha1"], pl.Files, exempt); err != nil {
+		return nil, fmt.Errorf("%w: %v", errorSourceMismatch, err)
+	}
+	expected, err := rb.subject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, v := range map[string]string{"node_version": toolchain.NodeVersion, "npm_version": toolchain.NpmVersion} {
+		if recorded, ok := rb.environment[name].(string); ok && recorded != v {
+			fmt.Printf("warning: %s is %s, the provenance records %s\n", name, v, recorded)
+		}
+	}
+
+	// Same as the build: all the packages are downloaded
+	// and verified against the lockfile.
+	if err := os.RemoveAll(npmCacheDir()); err != nil {
+		return nil, fmt.Errorf("os.RemoveAll: %w", err)
+	}
+	var paths []string
+	if rb.flag("npm_offline") {
+		l, err := lockfileFromDir(".")
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			if _, paths, err = buildTarballCache(l, tarballCacheDir(), httpFetch); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	commands, err := rb.commands(toolchain, paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commands {
+		fmt.Println("command", c)
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Env = os.Environ()
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.Join(c[1:3], " "), err)
+		}
+	}
+
+	if rb.flag("npm_pack_normalized") {
+		if recorded, ok := rb.environment["npm_pack_go_version"].(string); ok && recorded != runtime.Version() {
+			fmt.Printf("warning: the tarball is normalized with %s, the provenance records %s\n", runtime.Version(), recorded)
+		}
+		// The content policy of the build is not recorded:
+		// the default size limits apply.
+		if err := normalizeTarball(filename, &ContentPolicy{}); err != nil {
+			return nil, err
+		}
+	}
+
+	digests, err := fileDigests(filename)
+	if err != nil {
+		return nil, err
+	}
+	res := &RebuildResult{
+		Package:      filename,
+		Expected:     expected.Digest,
+		Digest:       digests,
+		Reproducible: verifyIntegrity(digests, expected.Digest) == nil,
+	}
+	if res.Reproducible || published == "" {
+		return res, nil
+	}
+
+	pdigests, err := fileDigests(published)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIntegrity(pdigests, expected.Digest); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errorPublishedMismatch, published, err)
+	}
+	res.Diff, err = diffTarballs(published, filename)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// diffTarballs lists the differences between the files of
+// the tarballs expected and actual.
+func diffTarballs(expected, actual string) ([]string, error) {
+	// Only the files are compared: the content policy was
+	// enforced when the package was built.
+	policy := &ContentPolicy{Allow: []string{"*"}}
+	em, err := inspectTarball(expected, policy)
+	if err != nil {
+		return nil, err
+	}
+	am, err := inspectTarball(actual, policy)
+	if err != nil {
+		return nil, err
+	}
+	return diffManifests(em, am), nil
+}
+
+// diffManifests lists the files removed (-), added (+) or
+// changed (~) in actual, sorted by path.
+func diffManifests(expected, actual *Manifest) []string {
+	files := make(map[string]ManifestFile)
+	for _, f := range expected.Files {
+		files[f.Path] = f
+	}
+
+	diff := make(map[string]string)
+	for _, a := range actual.Files {
+		e, exists := files[a.Path]
+		delete(files, a.Path)
+		if !exists {
+			diff[a.Path] = "+ " + a.Path
+			continue
+		}
+
+		var changes []string
+		if e.Size != a.Size {
+			changes = append(changes, fmt.Sprintf("size %d, expected %d", a.Size, e.Size))
+		}
+		if e.Digest["sha256"] != a.Digest["sha256"] {
+			changes = append(changes, "content")
+		}
+		if e.Mode != a.Mode {
+			changes = append(changes, fmt.Sprintf("mode %s, expected %s", a.Mode, e.Mode))
+		}
+		if e.Link != a.Link {
+			changes = append(changes, fmt.Sprintf("link %q, expected %q", a.Link, e.Link))
+		}
+		if len(changes) > 0 {
+			diff[a.Path] = fmt.Sprintf("~ %s: %s", a.Path, strings.Join(changes, ", "))
+		}
+	}
+	for p := range files {
+		diff[p] = "- " + p
+	}
+
+	paths := make([]string, 0, len(diff))
+	for p := range diff {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	res := make([]string, 0, len(paths))
+	for _, p := range paths {
+		res = append(res, diff[p])
+	}
+	if len(res) == 0 {
+		res = append(res, "the files are identical: the tarballs differ in the order, metadata or compression of the entries")
+	}
+	return res
+}
@@ -0,0 +1,332 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// testEnvelope wraps the statement in the file fn in an unsigned envelope.
+func testEnvelope(t *testing.T, fn, payloadType string, edit func(string) string) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	if edit != nil {
+		b = []byte(edit(string(b)))
+	}
+
+	env, err := json.Marshal(dsse.Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(b),
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return env
+}
+
+func Test_recordedBuildFromEnvelope(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		path        string
+		payloadType string
+		edit        func(string) string
+		expected    error
+	}{
+		{
+			name: "v0.2",
+			path: "./testdata/provenance-v0.2.golden.json",
+		},
+		{
+			name: "v1",
+			path: "./testdata/provenance-v1.golden.json",
+		},
+		{
+			name:        "payload type",
+			path:        "./testdata/provenance-v1.golden.json",
+			payloadType: "application/json",
+			expected:    errorInvalidProvenance,
+		},
+		{
+			name: "build type",
+			path: "./testdata/provenance-v1.golden.json",
+			edit: func(s string) string {
+				return strings.Replace(s, BuildTypeNode, "https://example.com/buildtypes/go/v1", 1)
+			},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name: "predicate type",
+			path: "./testdata/provenance-v1.golden.json",
+			edit: func(s string) string {
+				return strings.Replace(s, PredicateSLSAProvenanceV1, "https://slsa.dev/provenance/v0.1", 1)
+			},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name: "step without arguments",
+			path: "./testdata/provenance-v1.golden.json",
+			edit: func(s string) string {
+				return regexp.MustCompile(`,\s*"pack"`).ReplaceAllString(s, "")
+			},
+			expected: errorInvalidProvenance,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadType := tt.payloadType
+			if payloadType == "" {
+				payloadType = intoto.PayloadType
+			}
+
+			rb, err := recordedBuildFromEnvelope(testEnvelope(t, tt.path, payloadType, tt.edit))
+			if !errCmp(err, tt.expected) {
+				t.Error(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+
+			if commit := rb.source.Digest["sha1"]; commit != "6113728f27ae82c7b1a177c8d03f9e96e0adf246" {
+				t.Errorf("commit: %s", commit)
+			}
+			if !rb.flag("npm_offline") || !rb.flag("npm_pack_normalized") {
+				t.Errorf("environment: %v", rb.environment)
+			}
+
+			s, err := rb.subject("slsa-github-generator-node-test-1.0.1.tgz")
+			if err != nil {
+				t.Fatalf("subject: %v", err)
+			}
+			if s.Digest["sha256"] != "0ae7e4fa71686538440012ee36a2634dbaa19df2dd16a466f52411fb348bbc4e" {
+				t.Errorf("digest: %v", s.Digest)
+			}
+
+			if n := len(rb.buildConfig.Steps); n != 3 {
+				t.Errorf("steps: %d", n)
+			}
+		})
+	}
+}
+
+func Test_recordedBuild_commands(t *testing.T) {
+	t.Parallel()
+
+	rb, err := recordedBuildFromEnvelope(testEnvelope(t, "./testdata/provenance-v1.golden.json", intoto.PayloadType, nil))
+	if err != nil {
+		t.Fatalf("recordedBuildFromEnvelope: %v", err)
+	}
+	if !rb.flag("npm_offline") {
+		t.Fatalf("environment: %v", rb.environment)
+	}
+
+	// The tarballs of the offline install are downloaded
+	// and verified by the rebuild.
+	registry := map[string][]byte{
+		"https://registry.npmjs.org/a/-/a-1.0.0.tgz": tarballA,
+	}
+	l := &Lockfile{
+		Packages: map[string]*LockedPackage{
+			"node_modules/a": {
+				Name: "a", Version: "1.0.0",
+				Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+				Integrity: integrityOf(tarballA),
+			},
+		},
+	}
+	_, paths, err := buildTarballCache(l, t.TempDir(), testFetch(registry, make(map[string]int)))
+	if err != nil {
+		t.Fatalf("buildTarballCache: %v", err)
+	}
+
+	toolchain := &Toolchain{NodePath: "/opt/node/bin/node", NpmPath: "/opt/node/bin/npm"}
+	commands, err := rb.commands(toolchain, paths)
+	if err != nil {
+		t.Fatalf("commands: %v", err)
+	}
+	expected := [][]string{
+		{
+			"/opt/node/bin/node", "/opt/node/bin/npm", "cache", "add",
+			"--cache", npmCacheDir(), "--offline", paths[0],
+		},
+		{
+			"/opt/node/bin/node", "/opt/node/bin/npm", "ci", "--ignore-scripts",
+			"--cache", npmCacheDir(), "--no-audit", "--no-fund", "--offline",
+		},
+		{"/opt/node/bin/node", "/opt/node/bin/npm", "pack"},
+	}
+	if diff := cmp.Diff(expected, commands); diff != "" {
+		t.Error(diff)
+	}
+
+	// The tarballs cannot be installed offline if they
+	// are not added to the cache.
+	rb.buildConfig.Steps = rb.buildConfig.Steps[1:]
+	if _, err := rb.commands(toolchain, paths); !errCmp(err, errorInvalidProvenance) {
+		t.Error(cmp.Diff(err, errorInvalidProvenance))
+	}
+}
+
+func Test_recordedBuild_commands_unsupported(t *testing.T) {
+	t.Parallel()
+
+	node := "/usr/local/bin/node"
+	npm := "/usr/local/bin/npm"
+	cache := []string{node, npm, "cache", "add", "--cache", "/tmp/npm-cache", "--offline", "/tmp/a.tgz"}
+	install := []string{node, npm, "ci", "--ignore-scripts", "--cache", "/tmp/npm-cache", "--no-audit", "--no-fund"}
+	pack := []string{node, npm, "pack"}
+
+	tests := []struct {
+		name     string
+		offline  bool
+		steps    []Step
+		expected error
+	}{
+		{
+			name:  "install and pack",
+			steps: []Step{{Command: install}, {Command: pack}},
+		},
+		{
+			name:    "offline install",
+			offline: true,
+			steps:   []Step{{Command: cache}, {Command: append(install, "--offline")}, {Command: pack}},
+		},
+		{
+			name:     "other npm command",
+			steps:    []Step{{Command: []string{node, npm, "exec", "--", "sh", "-c", "id"}}, {Command: pack}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "install with other flags",
+			steps:    []Step{{Command: append(install, "--registry=https://example.com")}, {Command: pack}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "pack with arguments",
+			steps:    []Step{{Command: install}, {Command: append(pack, "--pack-destination", "/etc")}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "pack before install",
+			steps:    []Step{{Command: pack}, {Command: install}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "cache without offline install",
+			steps:    []Step{{Command: cache}, {Command: install}, {Command: pack}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "env variable not allowed",
+			steps:    []Step{{Command: install, Env: []string{"LD_PRELOAD=/tmp/lib.so"}}, {Command: pack}},
+			expected: errorInvalidProvenance,
+		},
+		{
+			name:     "node env variable",
+			steps:    []Step{{Command: install}, {Command: pack, Env: []string{"NODE_OPTIONS=--require=/tmp/a.js"}}},
+			expected: errorInvalidProvenance,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rb := &recordedBuild{
+				buildConfig: BuildConfig{Version: 1, Steps: tt.steps},
+				environment: map[string]interface{}{"npm_offline": tt.offline},
+			}
+			toolchain := &Toolchain{NodePath: "/opt/node/bin/node", NpmPath: "/opt/node/bin/npm"}
+			commands, err := rb.commands(toolchain, nil)
+			if !errCmp(err, tt.expected) {
+				t.Fatal(cmp.Diff(err, tt.expected))
+			}
+			for _, c := range commands {
+				if c[0] != toolchain.NodePath || c[1] != toolchain.NpmPath {
+					t.Errorf("command: %q", c)
+				}
+			}
+		})
+	}
+}
+
+func Test_diffManifests(t *testing.T) {
+	t.Parallel()
+
+	index := ManifestFile{Path: "lib/index.js", Size: 20, Mode: "0644", Digest: map[string]string{"sha256": "aa"}}
+	pkgJson := ManifestFile{Path: "package.json", Size: 15, Mode: "0644", Digest: map[string]string{"sha256": "bb"}}
+
+	tests := []struct {
+		name     string
+		expected []ManifestFile
+		actual   []ManifestFile
+		diff     []string
+	}{
+		{
+			name:     "identical files",
+			expected: []ManifestFile{index, pkgJson},
+			actual:   []ManifestFile{pkgJson, index},
+			diff: []string{
+				"the files are identical: the tarballs differ in the order, metadata or compression of the entries",
+			},
+		},
+		{
+			name:     "added and removed",
+			expected: []ManifestFile{index, pkgJson},
+			actual: []ManifestFile{
+				{Path: "dist/index.js", Size: 20, Mode: "0644", Digest: map[string]string{"sha256": "cc"}},
+				pkgJson,
+			},
+			diff: []string{"+ dist/index.js", "- lib/index.js"},
+		},
+		{
+			name:     "changed",
+			expected: []ManifestFile{index, pkgJson},
+			actual: []ManifestFile{
+				{Path: "lib/index.js", Size: 21, Mode: "0755", Digest: map[string]string{"sha256": "cc"}},
+				pkgJson,
+			},
+			diff: []string{"~ lib/index.js: size 21, expected 20, content, mode 0755, expected 0644"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff := diffManifests(&Manifest{Files: tt.expected}, &Manifest{Files: tt.actual})
+			if d := cmp.Diff(tt.diff, diff); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	errorInvalidRegistryPolicy = errors.New("invalid registry policy")
+	errorUnexpectedRegistry    = errors.New("dependency not resolved from an allowed registry")
+	errorDependencyConfusion   = errors.New("scoped dependency not resolved from the registry of its scope")
+)
+
+// defaultRegistries are the registries of the packages if the policy does
+// not set one.
+var defaultRegistries = []string{npmRegistry}
+
+// RegistryPolicy restricts where the packages of the lockfile are resolved
+// from. It is defined in the releaser config.
+type RegistryPolicy struct {
+	// Default is the registry of the unscoped packages and of the
+	// scopes not listed in Scopes. It defaults to the npm registry.
+	Default string `yaml:"default"`
+	// Scopes are the registries of scopes, e.g. @my-org. Packages of
+	// these scopes must be resolved from their registry, so that
+	// internal packages are not substituted by public packages.
+	Scopes map[string]string `yaml:"scopes"`
+	// AllowedHosts are the hosts packages of scopes not listed in Scopes
+	// may be downloaded from outside of their registry, e.g. tarball
+	// URLs or git repositories.
+	AllowedHosts []string `yaml:"allowed-hosts"`
+	// AllowMissingIntegrity allows tarballs without integrity in the
+	// lockfile, which cannot be verified when they are downloaded.
+	AllowMissingIntegrity bool `yaml:"allow-missing-integrity"`
+}
+
+func (p *RegistryPolicy) validate() error {
+	registries := []string{}
+	if p.Default != "" {
+		registries = append(registries, p.Default)
+	}
+	for scope, r := range p.Scopes {
+		if !strings.HasPrefix(scope, "@") || strings.Contains(scope, "/") {
+			return fmt.Errorf("%w: scope %q", errorInvalidRegistryPolicy, scope)
+		}
+		registries = append(registries, r)
+	}
+
+	for _, r := range registries {
+		u, err := url.Parse(r)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: registry %q is not an http(s) URL", errorInvalidRegistryPolicy, r)
+		}
+	}
+
+	for _, h := range p.AllowedHosts {
+		if h == "" || strings.ContainsAny(h, "/:") {
+			return fmt.Errorf("%w: host %q", errorInvalidRegistryPolicy, h)
+		}
+	}
+	return nil
+}
+
+// registries returns the registries a package may be resolved from.
+// The second value is true if the scope of the package has a registry.
+func (p *RegistryPolicy) registries(name string) ([]string, bool) {
+	if strings.HasPrefix(name, "@") {
+		scope := strings.SplitN(name, "/", 2)[0]
+		if r, exists := p.Scopes[scope]; exists {
+			return []string{r}, true
+		}
+	}
+	if p.Default != "" {
+		return []string{p.Default}, false
+	}
+	return defaultRegistries, false
+}
+
+// evaluate returns an error if the package is not resolved
+// from an allowed registry or host.
+func (p *RegistryPolicy) evaluate(name, resolved string) error {
+	registries, scoped := p.registries(name)
+	for _, r := range registries {
+		if strings.HasPrefix(resolved, strings.TrimSuffix(r, "/")+"/") {
+			return nil
+		}
+	}
+	if scoped {
+		return fmt.Errorf("%w: %s: %s", errorDependencyConfusion, name, resolved)
+	}
+
+	if host := resolvedHost(resolved); host != "" {
+		for _, h := range p.AllowedHosts {
+			if strings.EqualFold(h, host) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s: %s", errorUnexpectedRegistry, name, resolved)
+}
+
+// resolvedHost returns the host a package is downloaded from,
+// e.g. github.com for git+ssh://git@github.com/org/a.git
+// or github:org/a.
+func resolvedHost(resolved string) string {
+	if isGitResolved(resolved) {
+		resolved, _ = splitGitResolved(resolved)
+	}
+	u, err := url.Parse(resolved)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// evaluateRegistries verifies that the packages of the lockfile are
+// resolved from the registries allowed by the policy. Dependency
+// confusions are reported before other violations.
+func evaluateRegistries(l *Lockfile, policy *RegistryPolicy) error {
+	seen := make(map[string]bool)
+	violations := make(map[error][]string)
+	for _, p := range l.Packages {
+		// Links and packages of the workspace are part of the source.
+		if p.Resolved == "" {
+			continue
+		}
+
+		err := policy.evaluate(p.Name, p.Resolved)
+		if err == nil {
+			continue
+		}
+
+		id := fmt.Sprintf("%s (%s)", p.Name, p.Resolved)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		e := errorUnexpectedRegistry
+		if errors.Is(err, errorDependencyConfusion) {
+			e = errorDependencyConfusion
+		}
+		violations[e] = append(violations[e], id)
+	}
+
+	for _, e := range []error{errorDependencyConfusion, errorUnexpectedRegistry} {
+		if ids := violations[e]; len(ids) > 0 {
+			sort.Strings(ids)
+			return fmt.Errorf("%w: %s", e, strings.Join(ids, ", "))
+		}
+	}
+	return nil
+}
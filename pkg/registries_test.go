@@ -0,0 +1,194 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_RegistryPolicy_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policy   RegistryPolicy
+		expected error
+	}{
+		{
+			name: "valid",
+			policy: RegistryPolicy{
+				Default:      "https://registry.npmjs.org/",
+				Scopes:       map[string]string{"@my-org": "https://npm.pkg.github.com"},
+				AllowedHosts: []string{"codeload.github.com"},
+			},
+		},
+		{
+			name:   "empty",
+			policy: RegistryPolicy{},
+		},
+		{
+			name:     "invalid default",
+			policy:   RegistryPolicy{Default: "registry.npmjs.org"},
+			expected: errorInvalidRegistryPolicy,
+		},
+		{
+			name:     "scope without @",
+			policy:   RegistryPolicy{Scopes: map[string]string{"my-org": "https://npm.pkg.github.com"}},
+			expected: errorInvalidRegistryPolicy,
+		},
+		{
+			name:     "host with scheme",
+			policy:   RegistryPolicy{AllowedHosts: []string{"https://codeload.github.com"}},
+			expected: errorInvalidRegistryPolicy,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.policy.validate()
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_evaluateRegistries(t *testing.T) {
+	t.Parallel()
+
+	policy := RegistryPolicy{
+		Scopes:       map[string]string{"@my-org": "https://npm.pkg.github.com/"},
+		AllowedHosts: []string{"codeload.github.com"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   RegistryPolicy
+		packages map[string]*LockedPackage
+		expected error
+	}{
+		{
+			name:   "default registries",
+			policy: RegistryPolicy{},
+			packages: map[string]*LockedPackage{
+				"node_modules/a":        {Name: "a", Resolved: "https://registry.npmjs.org/a/-/a-1.0.0.tgz"},
+				"node_modules/@scope/c": {Name: "@scope/c", Resolved: "https://registry.npmjs.org/@scope/c/-/c-3.1.0.tgz"},
+				"node_modules/e":        {Name: "e", Link: "packages/e"},
+			},
+		},
+		{
+			name:   "scope registry",
+			policy: policy,
+			packages: map[string]*LockedPackage{
+				"node_modules/a":         {Name: "a", Resolved: "https://registry.npmjs.org/a/-/a-1.0.0.tgz"},
+				"node_modules/@my-org/b": {Name: "@my-org/b", Resolved: "https://npm.pkg.github.com/download/@my-org/b/1.0.0/abc"},
+			},
+		},
+		{
+			name:   "allowed host",
+			policy: policy,
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "https://codeload.github.com/org/a/tar.gz/0123456789abcdef"},
+			},
+		},
+		{
+			name:   "dependency confusion",
+			policy: policy,
+			packages: map[string]*LockedPackage{
+				"node_modules/@my-org/b": {Name: "@my-org/b", Resolved: "https://registry.npmjs.org/@my-org/b/-/b-1.0.0.tgz"},
+			},
+			expected: errorDependencyConfusion,
+		},
+		{
+			name: "dependency confusion through an allowed host",
+			policy: RegistryPolicy{
+				Scopes:       policy.Scopes,
+				AllowedHosts: []string{"registry.npmjs.org"},
+			},
+			packages: map[string]*LockedPackage{
+				"node_modules/@my-org/b": {Name: "@my-org/b", Resolved: "https://registry.npmjs.org/@my-org/b/-/b-1.0.0.tgz"},
+			},
+			expected: errorDependencyConfusion,
+		},
+		{
+			name:   "yarn mirror not allowed by default",
+			policy: RegistryPolicy{},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz"},
+			},
+			expected: errorUnexpectedRegistry,
+		},
+		{
+			name:   "yarn mirror as default registry",
+			policy: RegistryPolicy{Default: "https://registry.yarnpkg.com"},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "https://registry.yarnpkg.com/a/-/a-1.0.0.tgz"},
+			},
+		},
+		{
+			name:   "unexpected registry",
+			policy: policy,
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "https://registry.example.com/a/-/a-1.0.0.tgz"},
+			},
+			expected: errorUnexpectedRegistry,
+		},
+		{
+			name:   "registry prefix of the host",
+			policy: RegistryPolicy{Default: "https://registry.npmjs.org"},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "https://registry.npmjs.org.example.com/a/-/a-1.0.0.tgz"},
+			},
+			expected: errorUnexpectedRegistry,
+		},
+		{
+			name:   "git host not allowed",
+			policy: policy,
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "git+ssh://git@github.com/org/a.git#0123456789abcdef0123456789abcdef01234567"},
+			},
+			expected: errorUnexpectedRegistry,
+		},
+		{
+			name:   "git shorthand allowed",
+			policy: RegistryPolicy{AllowedHosts: []string{"github.com"}},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "github:org/a#0123456789abcdef0123456789abcdef01234567"},
+			},
+		},
+		{
+			name:   "git host allowed",
+			policy: RegistryPolicy{AllowedHosts: []string{"github.com"}},
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {Name: "a", Resolved: "git+ssh://git@github.com/org/a.git#0123456789abcdef0123456789abcdef01234567"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := evaluateRegistries(&Lockfile{Packages: tt.packages}, &tt.policy)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+)
+
+var errorInvalidBuildResult = errors.New("invalid build result")
+
+const buildResultVersion = 1
+
+// BuildResult is recorded by the builder during the build and
+// passed to the provenance generator.
+type BuildResult struct {
+	Version    int              `json:"version"`
+	Subjects   []intoto.Subject `json:"subjects,omitempty"`
+	StartedOn  *time.Time       `json:"startedOn,omitempty"`
+	FinishedOn *time.Time       `json:"finishedOn,omitempty"`
+	// Environment is the runner and toolchain the build ran on.
+	Environment *BuildEnvironment `json:"environment,omitempty"`
+	// Toolchain is the node toolchain the build used.
+	Toolchain *Toolchain `json:"toolchain,omitempty"`
+	// Dependencies are the packages resolved in the lockfile.
+	Dependencies []ResourceDescriptor `json:"dependencies,omitempty"`
+	// InstallScripts is the audit of the install scripts
+	// of the dependencies.
+	InstallScripts *InstallScripts `json:"installScripts,omitempty"`
+	// Install describes the install of the dependencies.
+	Install *InstallResult `json:"install,omitempty"`
+	// Pack describes the tarball of the package.
+	Pack *PackResult `json:"pack,omitempty"`
+	// SBOMs are the SBOMs of the packages written by the builder.
+	// They are byproducts of the provenance.
+	SBOMs []intoto.Subject `json:"sboms,omitempty"`
+	// Manifest is the manifest of the files of the tarball
+	// written by the builder. It is a byproduct of the provenance.
+	Manifest *intoto.Subject `json:"manifest,omitempty"`
+}
+
+func buildResultFromString(b []byte) (*BuildResult, error) {
+	var r BuildResult
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func BuildResultFromFile(pathfn string) (*BuildResult, error) {
+	r, err := os.ReadFile(pathfn)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	return buildResultFromString(r)
+}
+
+func WriteBuildResult(pathfn string, r *BuildResult) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	if err := os.WriteFile(pathfn, b, 0o600); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+	return nil
+}
+
+func (r *BuildResult) validate() error {
+	if r.Version != buildResultVersion {
+		return fmt.Errorf("%w:%d", errorUnsupportedVersion, r.Version)
+	}
+
+	if r.StartedOn != nil && r.FinishedOn != nil && r.FinishedOn.Before(*r.StartedOn) {
+		return fmt.Errorf("%w: build finished on %v before it started on %v",
+			errorInvalidBuildResult, r.FinishedOn, r.StartedOn)
+	}
+
+	if r.Toolchain != nil {
+		if err := validateDigestSet(r.Toolchain.NodeDigest); err != nil {
+			return fmt.Errorf("%w: node: %v", errorInvalidBuildResult, err)
+		}
+	}
+
+	if r.Install != nil {
+		for _, t := range r.Install.Tarballs {
+			if err := validateDigestSet(t.Digest); err != nil {
+				return fmt.Errorf("%w: tarball %s: %v", errorInvalidBuildResult, t.URI, err)
+			}
+		}
+		if len(r.Install.CacheCommand) > 0 && !isCacheAddCommand(r.Install.CacheCommand) {
+			return fmt.Errorf("%w: cache command %q", errorInvalidBuildResult, r.Install.CacheCommand)
+		}
+	}
+
+	return nil
+}
+
+// byproducts returns the files the builder wrote next to the package:
+// its SBOMs and the manifest of its files.
+func (r *BuildResult) byproducts() []intoto.Subject {
+	res := append([]intoto.Subject{}, r.SBOMs...)
+	if r.Manifest != nil {
+		res = append(res, *r.Manifest)
+	}
+	return res
+}
+
+// materialsComplete returns true if the build result records all the
+// inputs of the build besides the source: the toolchain, and the
+// packages of the lockfile with their digests, which npm verifies on
+// install. Install scripts of the dependencies may fetch other inputs,
+// so the materials are not complete unless install, the command of the
+// dry run, ignores them: the build result comes from the build job,
+// which runs the scripts of the project.
+func (r *BuildResult) materialsComplete(install []string) bool {
+	if r.Toolchain == nil || r.Install == nil || !ignoresScripts(install) {
+		return false
+	}
+	for _, d := range r.Dependencies {
+		if len(d.Digest) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoresScripts returns true if the install command does not run
+// the install scripts of the dependencies.
+func ignoresScripts(install []string) bool {
+	for _, arg := range install {
+		if arg == "--ignore-scripts" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSBOM verifies that the SBOM named name with content b was
+// written by the builder.
+func (r *BuildResult) hasSBOM(name string, b []byte) error {
+	digests := bytesDigests(b)
+	for _, s := range r.SBOMs {
+		if s.Name != name {
+			continue
+		}
+		if s.Digest["sha256"] != digests["sha256"] {
+			return fmt.Errorf("%w: %s: sha256 mismatch", errorSBOMNotInBuild, name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errorSBOMNotInBuild, name)
+}
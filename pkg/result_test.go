@@ -0,0 +1,153 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_BuildResultFromFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected error
+	}{
+		{
+			name:     "valid build result",
+			path:     "./testdata/build-result-valid.json",
+			expected: nil,
+		},
+		{
+			name:     "invalid version",
+			path:     "./testdata/build-result-invalid-version.json",
+			expected: errorUnsupportedVersion,
+		},
+		{
+			name:     "finished before started",
+			path:     "./testdata/build-result-invalid-timestamps.json",
+			expected: errorInvalidBuildResult,
+		},
+		{
+			name:     "invalid tarball digest",
+			path:     "./testdata/build-result-invalid-tarball.json",
+			expected: errorInvalidBuildResult,
+		},
+		{
+			name:     "invalid cache command",
+			path:     "./testdata/build-result-invalid-cache-command.json",
+			expected: errorInvalidBuildResult,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := BuildResultFromFile(tt.path)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_WriteBuildResult(t *testing.T) {
+	t.Parallel()
+
+	r, err := BuildResultFromFile("./testdata/build-result-valid.json")
+	if err != nil {
+		t.Fatalf("BuildResultFromFile: %v", err)
+	}
+
+	fn := filepath.Join(t.TempDir(), "build-result.json")
+	if err := WriteBuildResult(fn, r); err != nil {
+		t.Fatalf("WriteBuildResult: %v", err)
+	}
+
+	w, err := BuildResultFromFile(fn)
+	if err != nil {
+		t.Fatalf("BuildResultFromFile: %v", err)
+	}
+
+	if !cmp.Equal(w, r) {
+		t.Errorf(cmp.Diff(w, r))
+	}
+}
+
+func Test_BuildResult_materialsComplete(t *testing.T) {
+	t.Parallel()
+
+	install := []string{"/usr/local/bin/node", "/usr/local/bin/npm", "ci", "--ignore-scripts"}
+
+	tests := []struct {
+		name     string
+		edit     func(r *BuildResult)
+		install  []string
+		expected bool
+	}{
+		{
+			name:     "complete",
+			edit:     func(r *BuildResult) {},
+			install:  install,
+			expected: true,
+		},
+		{
+			name: "install scripts ran",
+			edit: func(r *BuildResult) {},
+			install: []string{
+				"/usr/local/bin/node", "/usr/local/bin/npm", "ci", "--ignore-scripts=false",
+			},
+		},
+		{
+			name: "install scripts ignored in the build result only",
+			edit: func(r *BuildResult) { r.InstallScripts.IgnoreScripts = true },
+			install: []string{
+				"/usr/local/bin/node", "/usr/local/bin/npm", "ci", "--ignore-scripts=false",
+			},
+		},
+		{
+			name: "dependency without digest",
+			edit: func(r *BuildResult) {
+				r.Dependencies = append(r.Dependencies, ResourceDescriptor{URI: "git+https://github.com/org/a.git", Name: "a"})
+			},
+			install: install,
+		},
+		{
+			name:    "toolchain not recorded",
+			edit:    func(r *BuildResult) { r.Toolchain = nil },
+			install: install,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := BuildResultFromFile("./testdata/build-result-valid.json")
+			if err != nil {
+				t.Fatalf("BuildResultFromFile: %v", err)
+			}
+			tt.edit(r)
+			if got := r.materialsComplete(tt.install); got != tt.expected {
+				t.Errorf("materials: %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
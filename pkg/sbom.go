@@ -0,0 +1,601 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorInvalidSBOM    = errors.New("invalid SBOM")
+	errorSBOMNotInBuild = errors.New("SBOM not recorded by the build")
+)
+
+const (
+	// sbomTool identifies the builder as the author of the SBOMs.
+	sbomTool = "slsa-github-generator-node"
+
+	// Extensions of the SBOMs written next to the package.
+	sbomExtCycloneDX = ".cdx.json"
+	sbomExtSPDX      = ".spdx.json"
+
+	// predicateCycloneDX is the in-toto predicate type of CycloneDX
+	// documents. The version is recorded in the document itself.
+	// See https://github.com/in-toto/attestation/blob/main/spec/predicates/cyclonedx.md.
+	predicateCycloneDX = "https://cyclonedx.org/bom"
+
+	noAssertion = "NOASSERTION"
+)
+
+// Names of the algorithms of a slsa.DigestSet in each format.
+var (
+	cdxHashAlgorithms = map[string]string{
+		"sha1":   "SHA-1",
+		"sha256": "SHA-256",
+		"sha384": "SHA-384",
+		"sha512": "SHA-512",
+	}
+	spdxChecksumAlgorithms = map[string]string{
+		"sha1":   "SHA1",
+		"sha256": "SHA256",
+		"sha384": "SHA384",
+		"sha512": "SHA512",
+	}
+)
+
+// sbomComponent is a package of the SBOM, identified by its purl.
+type sbomComponent struct {
+	purl     string
+	name     string
+	version  string
+	resolved string
+	digest   slsa.DigestSet
+	// dependsOn are the purls of the dependencies, sorted.
+	dependsOn []string
+}
+
+// sbomGraph is the package built and the packages it depends on at
+// runtime, independently of the format of the SBOM.
+type sbomGraph struct {
+	root       sbomComponent
+	components []sbomComponent
+}
+
+// CycloneDX 1.5 JSON.
+// See https://cyclonedx.org/docs/1.5/json/.
+type (
+	cdxBOM struct {
+		BOMFormat    string          `json:"bomFormat"`
+		SpecVersion  string          `json:"specVersion"`
+		SerialNumber string          `json:"serialNumber"`
+		Version      int             `json:"version"`
+		Metadata     cdxMetadata     `json:"metadata"`
+		Components   []cdxComponent  `json:"components"`
+		Dependencies []cdxDependency `json:"dependencies"`
+	}
+
+	cdxMetadata struct {
+		Timestamp string       `json:"timestamp"`
+		Tools     cdxTools     `json:"tools"`
+		Component cdxComponent `json:"component"`
+	}
+
+	cdxTools struct {
+		Components []cdxComponent `json:"components"`
+	}
+
+	cdxComponent struct {
+		Type               string                 `json:"type"`
+		BOMRef             string                 `json:"bom-ref,omitempty"`
+		Group              string                 `json:"group,omitempty"`
+		Name               string                 `json:"name"`
+		Version            string                 `json:"version,omitempty"`
+		PURL               string                 `json:"purl,omitempty"`
+		Hashes             []cdxHash              `json:"hashes,omitempty"`
+		ExternalReferences []cdxExternalReference `json:"externalReferences,omitempty"`
+	}
+
+	cdxHash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+
+	cdxExternalReference struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	cdxDependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	}
+)
+
+// SPDX 2.3 JSON.
+// See https://spdx.github.io/spdx-spec/v2.3/.
+type (
+	spdxDocument struct {
+		SPDXVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SPDXID            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+		Packages          []spdxPackage      `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}
+
+	spdxCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+
+	spdxPackage struct {
+		SPDXID           string            `json:"SPDXID"`
+		Name             string            `json:"name"`
+		VersionInfo      string            `json:"versionInfo"`
+		DownloadLocation string            `json:"downloadLocation"`
+		FilesAnalyzed    bool              `json:"filesAnalyzed"`
+		Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+		LicenseConcluded string            `json:"licenseConcluded"`
+		LicenseDeclared  string            `json:"licenseDeclared"`
+		ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+	}
+
+	spdxChecksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+
+	spdxExternalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+
+	spdxRelationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+)
+
+// WriteSBOMs writes the CycloneDX and SPDX SBOMs of the package
+// to dir, and returns their names and digests, recorded as byproducts
+// in the provenance. The SBOMs
+// list the packages the dependencies of package.json resolve to in
+// the lockfile of the project in projectDir.
+func WriteSBOMs(dir, projectDir, filename string, pkgJson *PkgJsonConfig,
+	digest slsa.DigestSet, timestamp time.Time) ([]intoto.Subject, error) {
+	l, err := lockfileFromDir(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		l.resolveRoot(pkgJson)
+	}
+
+	g, err := newSBOMGraph(pkgJson, digest, l)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	var subjects []intoto.Subject
+	for _, doc := range []struct {
+		ext string
+		v   interface{}
+	}{
+		{sbomExtCycloneDX, g.cycloneDX(timestamp)},
+		{sbomExtSPDX, g.spdx(timestamp)},
+	} {
+		b, err := json.MarshalIndent(doc.v, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("json.MarshalIndent: %w", err)
+		}
+
+		name := base + doc.ext
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			return nil, fmt.Errorf("os.WriteFile: %w", err)
+		}
+		subjects = append(subjects, NewSubject(name, bytesDigests(b)))
+	}
+	return subjects, nil
+}
+
+func bytesDigests(b []byte) slsa.DigestSet {
+	s256 := sha256.Sum256(b)
+	s512 := sha512.Sum512(b)
+	return slsa.DigestSet{
+		"sha256": hex.EncodeToString(s256[:]),
+		"sha512": hex.EncodeToString(s512[:]),
+	}
+}
+
+// newSBOMGraph returns the package and its runtime dependencies:
+// the dependencies of package.json and their own dependencies.
+// Development dependencies are not part of the package.
+// l is nil if the project has no lockfile.
+func newSBOMGraph(pkgJson *PkgJsonConfig, digest slsa.DigestSet, l *Lockfile) (*sbomGraph, error) {
+	if _, exists := digest["sha256"]; !exists {
+		return nil, fmt.Errorf("%w: missing sha256 digest of the package", errorInvalidSBOM)
+	}
+
+	g := &sbomGraph{
+		root: sbomComponent{
+			purl:    npmPURL(pkgJson.Name, pkgJson.Version),
+			name:    pkgJson.Name,
+			version: pkgJson.Version,
+			digest:  digest,
+			// Encoded as an empty list rather than null.
+			dependsOn: []string{},
+		},
+	}
+	if l == nil {
+		return g, nil
+	}
+
+	names := make([]string, 0, len(pkgJson.Dependencies))
+	for name := range pkgJson.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Links are replaced by the package they point to.
+	var purlOf func(key string) (string, bool)
+	purlOf = func(key string) (string, bool) {
+		p, exists := l.Packages[key]
+		if !exists {
+			return "", false
+		}
+		if p.Link != "" {
+			return purlOf(p.Link)
+		}
+		return npmPURL(p.Name, p.Version), true
+	}
+
+	// The same package may be installed at several paths.
+	byPURL := make(map[string]*sbomComponent)
+	for key := range l.closure(names) {
+		p := l.Packages[key]
+		if p.Link != "" {
+			continue
+		}
+
+		purl := npmPURL(p.Name, p.Version)
+		c, exists := byPURL[purl]
+		if !exists {
+			c = &sbomComponent{
+				purl:     purl,
+				name:     p.Name,
+				version:  p.Version,
+				resolved: p.Resolved,
+			}
+			if p.Integrity != "" {
+				digests, err := DigestSetFromString(p.Integrity)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s: %v", errorInvalidLockfile, key, err)
+				}
+				c.digest = digests
+			}
+			byPURL[purl] = c
+		}
+
+		for _, k := range p.Dependencies {
+			if d, exists := purlOf(k); exists {
+				c.dependsOn = append(c.dependsOn, d)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if key, exists := l.Dependencies[name]; exists {
+			if d, exists := purlOf(key); exists {
+				g.root.dependsOn = append(g.root.dependsOn, d)
+			}
+		}
+	}
+	g.root.dependsOn = sortedUnique(g.root.dependsOn)
+
+	purls := make([]string, 0, len(byPURL))
+	for purl := range byPURL {
+		purls = append(purls, purl)
+	}
+	sort.Strings(purls)
+
+	for _, purl := range purls {
+		c := byPURL[purl]
+		c.dependsOn = sortedUnique(c.dependsOn)
+		g.components = append(g.components, *c)
+	}
+	return g, nil
+}
+
+func sortedUnique(s []string) []string {
+	res := []string{}
+	seen := make(map[string]bool)
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			res = append(res, v)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// npmPURL returns the package URL of an npm package.
+// See https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#npm.
+func npmPURL(name, version string) string {
+	// The @ of the scope is percent-encoded.
+	return fmt.Sprintf("pkg:npm/%s@%s", strings.Replace(name, "@", "%40", 1), url.PathEscape(version))
+}
+
+// serialUUID returns a UUID derived from the sha256 digest of the
+// package, so that the SBOMs of a package are reproducible.
+func (g *sbomGraph) serialUUID() string {
+	b, err := hex.DecodeString(g.root.digest["sha256"])
+	if err != nil || len(b) < 16 {
+		b = make([]byte, 16)
+	}
+	u := b[:16]
+	// Version 5 and RFC 4122 variant, as for a name-based UUID.
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func sortedAlgorithms(d slsa.DigestSet, names map[string]string) []string {
+	var algs []string
+	for alg := range d {
+		if _, exists := names[alg]; exists {
+			algs = append(algs, alg)
+		}
+	}
+	sort.Strings(algs)
+	return algs
+}
+
+func (c *sbomComponent) cycloneDX() cdxComponent {
+	res := cdxComponent{
+		Type:    "library",
+		BOMRef:  c.purl,
+		Name:    c.name,
+		Version: c.version,
+		PURL:    c.purl,
+	}
+	// The scope is the group of scoped packages.
+	if strings.HasPrefix(c.name, "@") {
+		if i := strings.Index(c.name, "/"); i > 0 {
+			res.Group = c.name[:i]
+			res.Name = c.name[i+1:]
+		}
+	}
+	for _, alg := range sortedAlgorithms(c.digest, cdxHashAlgorithms) {
+		res.Hashes = append(res.Hashes, cdxHash{
+			Alg:     cdxHashAlgorithms[alg],
+			Content: c.digest[alg],
+		})
+	}
+	if c.resolved != "" {
+		res.ExternalReferences = []cdxExternalReference{
+			{
+				Type: "distribution",
+				URL:  c.resolved,
+			},
+		}
+	}
+	return res
+}
+
+// cycloneDX encodes the graph as a CycloneDX 1.5 BOM.
+func (g *sbomGraph) cycloneDX(timestamp time.Time) *cdxBOM {
+	bom := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + g.serialUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{
+					{
+						Type: "application",
+						Name: sbomTool,
+					},
+				},
+			},
+			Component: g.root.cycloneDX(),
+		},
+		Components: []cdxComponent{},
+		Dependencies: []cdxDependency{
+			{
+				Ref:       g.root.purl,
+				DependsOn: g.root.dependsOn,
+			},
+		},
+	}
+
+	for i := range g.components {
+		c := &g.components[i]
+		bom.Components = append(bom.Components, c.cycloneDX())
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{
+			Ref:       c.purl,
+			DependsOn: c.dependsOn,
+		})
+	}
+	return bom
+}
+
+func (c *sbomComponent) spdx(id string) spdxPackage {
+	res := spdxPackage{
+		SPDXID:           id,
+		Name:             c.name,
+		VersionInfo:      c.version,
+		DownloadLocation: noAssertion,
+		FilesAnalyzed:    false,
+		LicenseConcluded: noAssertion,
+		LicenseDeclared:  noAssertion,
+		ExternalRefs: []spdxExternalRef{
+			{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.purl,
+			},
+		},
+	}
+	if c.resolved != "" {
+		res.DownloadLocation = c.resolved
+	}
+	for _, alg := range sortedAlgorithms(c.digest, spdxChecksumAlgorithms) {
+		res.Checksums = append(res.Checksums, spdxChecksum{
+			Algorithm:     spdxChecksumAlgorithms[alg],
+			ChecksumValue: c.digest[alg],
+		})
+	}
+	return res
+}
+
+// spdx encodes the graph as an SPDX 2.3 document.
+func (g *sbomGraph) spdx(timestamp time.Time) *spdxDocument {
+	const (
+		documentID = "SPDXRef-DOCUMENT"
+		rootID     = "SPDXRef-Package"
+	)
+
+	ids := map[string]string{
+		g.root.purl: rootID,
+	}
+	for i, c := range g.components {
+		ids[c.purl] = fmt.Sprintf("%s-%d", rootID, i+1)
+	}
+
+	name := fmt.Sprintf("%s@%s", g.root.name, g.root.version)
+	doc := &spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      documentID,
+		Name:        name,
+		DocumentNamespace: fmt.Sprintf("https://github.com/bcoe/slsa-github-generator-node/spdx/%s/%s",
+			url.PathEscape(name), g.serialUUID()),
+		CreationInfo: spdxCreationInfo{
+			Created:  timestamp.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomTool},
+		},
+		Packages: []spdxPackage{g.root.spdx(rootID)},
+		Relationships: []spdxRelationship{
+			{
+				SPDXElementID:      documentID,
+				RelationshipType:   "DESCRIBES",
+				RelatedSPDXElement: rootID,
+			},
+		},
+	}
+
+	for _, c := range append([]sbomComponent{g.root}, g.components...) {
+		if c.purl != g.root.purl {
+			doc.Packages = append(doc.Packages, c.spdx(ids[c.purl]))
+		}
+		for _, d := range c.dependsOn {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SPDXElementID:      ids[c.purl],
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: ids[d],
+			})
+		}
+	}
+	return doc
+}
+
+// sbomStatement returns an in-toto statement whose predicate is the
+// SBOM and whose subjects are the packages built. The SBOM must be
+// one of the SBOMs recorded in the build result, so that it is bound
+// to the provenance of the packages.
+func sbomStatement(name string, b []byte, r *BuildResult) (*intoto.Statement, error) {
+	if err := r.hasSBOM(name, b); err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		BOMFormat   string `json:"bomFormat"`
+		SPDXVersion string `json:"spdxVersion"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("%w: json.Unmarshal: %v", errorInvalidSBOM, err)
+	}
+
+	var predicateType string
+	switch {
+	case doc.BOMFormat == "CycloneDX":
+		predicateType = predicateCycloneDX
+	case strings.HasPrefix(doc.SPDXVersion, "SPDX-"):
+		predicateType = intoto.PredicateSPDX
+	default:
+		return nil, fmt.Errorf("%w: %s: unknown format", errorInvalidSBOM, name)
+	}
+
+	subjects, err := validateSubjects(r.Subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	return &intoto.Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: predicateType,
+			Subject:       subjects,
+		},
+		Predicate: json.RawMessage(b),
+	}, nil
+}
+
+// GenerateSBOMAttestation signs an SBOM written by the build as an
+// in-toto attestation about the packages in the build result.
+func GenerateSBOMAttestation(pathfn string, r *BuildResult) ([]byte, error) {
+	b, err := os.ReadFile(pathfn)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	att, err := sbomStatement(filepath.Base(pathfn), b, r)
+	if err != nil {
+		return nil, err
+	}
+
+	attBytes, err := json.Marshal(att)
+	if err != nil {
+		return nil, err
+	}
+
+	return signAttestation(attBytes)
+}
@@ -0,0 +1,262 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	sbomPkgJson = &PkgJsonConfig{
+		Name:    "test",
+		Version: "1.0.1",
+		Dependencies: map[string]string{
+			"a":        "^1.0.0",
+			"@scope/c": "^3.0.0",
+			"e":        "^0.0.1",
+		},
+	}
+	sbomDigest = slsa.DigestSet{
+		"sha256": "0ae7e4fa71686538440012ee36a2634dbaa19df2dd16a466f52411fb348bbc4e",
+	}
+	sbomTimestamp = time.Date(2022, 4, 21, 17, 52, 13, 0, time.UTC)
+)
+
+func Test_WriteSBOMs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	subjects, err := WriteSBOMs(dir, "./testdata/lockfile/npm-v3", "test-1.0.1.tgz",
+		sbomPkgJson, sbomDigest, sbomTimestamp)
+	if err != nil {
+		t.Fatalf("WriteSBOMs: %v", err)
+	}
+
+	var names []string
+	for _, s := range subjects {
+		names = append(names, s.Name)
+
+		b, err := os.ReadFile(filepath.Join(dir, s.Name))
+		if err != nil {
+			t.Fatalf("os.ReadFile: %v", err)
+		}
+		if diff := cmp.Diff(bytesDigests(b), s.Digest); diff != "" {
+			t.Errorf(diff)
+		}
+		checkGolden(t, filepath.Join("testdata", "sbom", s.Name), b)
+	}
+
+	expected := []string{"test-1.0.1.cdx.json", "test-1.0.1.spdx.json"}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func Test_newSBOMGraph(t *testing.T) {
+	t.Parallel()
+
+	// The runtime dependencies: d is a development dependency,
+	// and e is a package of the workspace.
+	expectedRoot := []string{"pkg:npm/%40scope/c@3.1.0", "pkg:npm/a@1.0.0", "pkg:npm/e@0.0.1"}
+	expectedComponents := map[string][]string{
+		"pkg:npm/%40scope/c@3.1.0": {"pkg:npm/b@1.0.0"},
+		"pkg:npm/a@1.0.0":          {"pkg:npm/b@2.0.0"},
+		"pkg:npm/b@1.0.0":          {},
+		"pkg:npm/b@2.0.0":          {},
+		"pkg:npm/e@0.0.1":          {"pkg:npm/b@1.0.0"},
+	}
+
+	tests := []struct {
+		name     string
+		dir      string
+		digest   slsa.DigestSet
+		expected error
+	}{
+		{
+			name:   "npm v2",
+			dir:    "./testdata/lockfile/npm-v2",
+			digest: sbomDigest,
+		},
+		{
+			name:   "npm v3",
+			dir:    "./testdata/lockfile/npm-v3",
+			digest: sbomDigest,
+		},
+		{
+			name:     "missing sha256",
+			dir:      "./testdata/lockfile/npm-v3",
+			digest:   slsa.DigestSet{"sha512": "abcd"},
+			expected: errorInvalidSBOM,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := lockfileFromDir(tt.dir)
+			if err != nil {
+				t.Fatalf("lockfileFromDir: %v", err)
+			}
+			l.resolveRoot(sbomPkgJson)
+
+			g, err := newSBOMGraph(sbomPkgJson, tt.digest, l)
+			if !errCmp(err, tt.expected) {
+				t.Fatalf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+
+			// purls contain %.
+			if diff := cmp.Diff(expectedRoot, g.root.dependsOn); diff != "" {
+				t.Error(diff)
+			}
+
+			components := make(map[string][]string)
+			for _, c := range g.components {
+				components[c.purl] = c.dependsOn
+			}
+			if diff := cmp.Diff(expectedComponents, components); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_newSBOMGraph_noLockfile(t *testing.T) {
+	t.Parallel()
+
+	g, err := newSBOMGraph(sbomPkgJson, sbomDigest, nil)
+	if err != nil {
+		t.Fatalf("newSBOMGraph: %v", err)
+	}
+
+	if len(g.components) != 0 || len(g.root.dependsOn) != 0 {
+		t.Errorf("unexpected dependencies: %v", g.components)
+	}
+}
+
+func Test_npmPURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		version  string
+		expected string
+	}{
+		{name: "a", version: "1.0.0", expected: "pkg:npm/a@1.0.0"},
+		{name: "@scope/a", version: "1.0.0", expected: "pkg:npm/%40scope/a@1.0.0"},
+		{name: "a", version: "1.0.0-rc.1+build.1", expected: "pkg:npm/a@1.0.0-rc.1+build.1"},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.expected, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, npmPURL(tt.name, tt.version)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func Test_sbomStatement(t *testing.T) {
+	t.Parallel()
+
+	cdx, err := os.ReadFile("./testdata/sbom/test-1.0.1.cdx.json")
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+	spdx, err := os.ReadFile("./testdata/sbom/test-1.0.1.spdx.json")
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+
+	pkgSubject := NewSubject("test-1.0.1.tgz", sbomDigest)
+	result := &BuildResult{
+		Version:  buildResultVersion,
+		Subjects: []intoto.Subject{pkgSubject},
+		SBOMs: []intoto.Subject{
+			NewSubject("test-1.0.1.cdx.json", bytesDigests(cdx)),
+			NewSubject("test-1.0.1.spdx.json", bytesDigests(spdx)),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		sbom          string
+		content       []byte
+		predicateType string
+		expected      error
+	}{
+		{
+			name:          "cyclonedx",
+			sbom:          "test-1.0.1.cdx.json",
+			content:       cdx,
+			predicateType: predicateCycloneDX,
+		},
+		{
+			name:          "spdx",
+			sbom:          "test-1.0.1.spdx.json",
+			content:       spdx,
+			predicateType: intoto.PredicateSPDX,
+		},
+		{
+			name:     "modified sbom",
+			sbom:     "test-1.0.1.cdx.json",
+			content:  spdx,
+			expected: errorSBOMNotInBuild,
+		},
+		{
+			name:     "unknown sbom",
+			sbom:     "other.cdx.json",
+			content:  cdx,
+			expected: errorSBOMNotInBuild,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			st, err := sbomStatement(tt.sbom, tt.content, result)
+			if !errCmp(err, tt.expected) {
+				t.Fatalf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tt.predicateType, st.PredicateType); diff != "" {
+				t.Errorf(diff)
+			}
+			if diff := cmp.Diff([]intoto.Subject{pkgSubject}, st.Subject); diff != "" {
+				t.Errorf(diff)
+			}
+			if !json.Valid(st.Predicate.(json.RawMessage)) {
+				t.Errorf("invalid predicate")
+			}
+		})
+	}
+}
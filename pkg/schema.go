@@ -0,0 +1,420 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var errorSchemaValidation = errors.New("schema validation failed")
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema needed to describe
+// the buildConfig and parameters of the Node buildType.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+}
+
+// UnmarshalJSON decodes additionalProperties, which is either
+// a boolean or a schema.
+func (s *jsonSchema) UnmarshalJSON(b []byte) error {
+	type schema jsonSchema
+	raw := struct {
+		*schema
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+	}{
+		schema: (*schema)(s),
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	s.AdditionalProperties = nil
+	if len(raw.AdditionalProperties) == 0 {
+		return nil
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(raw.AdditionalProperties, &allowed); err == nil {
+		s.AdditionalProperties = allowed
+		return nil
+	}
+
+	var ap jsonSchema
+	if err := json.Unmarshal(raw.AdditionalProperties, &ap); err != nil {
+		return err
+	}
+	s.AdditionalProperties = &ap
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// BuildTypeSchema returns the JSON Schema of the buildConfig and
+// parameters fields for BuildTypeNode. The schema is generated
+// from the Go types so that it cannot drift from the provenance
+// we generate.
+func BuildTypeSchema() ([]byte, error) {
+	s := buildTypeSchema()
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.MarshalIndent: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+func buildTypeSchema() *jsonSchema {
+	buildConfig := schemaFromType(reflect.TypeOf(BuildConfig{}))
+	buildConfig.Properties["version"].Const = buildTypeVersion
+	parameters := schemaFromType(reflect.TypeOf(Parameters{}))
+	parameters.Properties["version"].Const = buildTypeVersion
+	parameters.Properties["event_payload"] = eventPayloadSchema()
+
+	return &jsonSchema{
+		Schema: jsonSchemaDraft,
+		ID:     BuildTypeNode + "/schema.json",
+		Title:  "SLSA buildType for Node.js packages",
+		Type:   "object",
+		Properties: map[string]*jsonSchema{
+			"buildConfig": buildConfig,
+			"parameters":  parameters,
+		},
+		Required:             []string{"buildConfig", "parameters"},
+		AdditionalProperties: false,
+	}
+}
+
+// eventPayloadSchema returns the schema of the redacted event
+// payloads recorded by parseEventPayload. The payload of
+// unsupported events is null.
+func eventPayloadSchema() *jsonSchema {
+	events := []struct {
+		name    string
+		payload interface{}
+	}{
+		{"push", PushEvent{}},
+		{"release", ReleaseEvent{}},
+		{"workflow_dispatch", WorkflowDispatchEvent{}},
+		{"pull_request", PullRequestEvent{}},
+	}
+
+	s := &jsonSchema{
+		OneOf: []*jsonSchema{{Type: "null"}},
+	}
+	for _, e := range events {
+		es := schemaFromType(reflect.TypeOf(e.payload))
+		es.Title = e.name
+		s.OneOf = append(s.OneOf, es)
+	}
+	return s
+}
+
+func schemaFromType(t reflect.Type) *jsonSchema {
+	if t == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFromType(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: schemaFromType(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: schemaFromType(t.Elem())}
+	case reflect.Struct:
+		return schemaFromStruct(t)
+	default:
+		// interface{}: any value is accepted.
+		return &jsonSchema{}
+	}
+}
+
+func schemaFromStruct(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+
+		name, omitempty := parseJSONTag(f)
+		if name == "-" {
+			continue
+		}
+
+		fs := schemaFromType(f.Type)
+		if !omitempty {
+			s.Required = append(s.Required, name)
+			// encoding/json encodes nil pointers and maps as null.
+			if k := f.Type.Kind(); k == reflect.Ptr || k == reflect.Map {
+				fs = &jsonSchema{OneOf: []*jsonSchema{{Type: "null"}, fs}}
+			}
+		}
+		s.Properties[name] = fs
+	}
+
+	return s
+}
+
+func parseJSONTag(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "" {
+		return f.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+
+	for _, o := range parts[1:] {
+		if o == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// validateBuildType validates the buildConfig and parameters
+// against the schema of BuildTypeNode.
+func validateBuildType(buildConfig, parameters interface{}) error {
+	// Round-trip through JSON so that we validate exactly what
+	// ends up in the attestation.
+	b, err := json.Marshal(map[string]interface{}{
+		"buildConfig": buildConfig,
+		"parameters":  parameters,
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return buildTypeSchema().validate(v, "")
+}
+
+// validateStatement validates the buildConfig and parameters of the
+// serialized provenance statement b against the schema of BuildTypeNode.
+func validateStatement(b []byte) error {
+	return buildTypeSchema().validateStatement(b)
+}
+
+func (s *jsonSchema) validateStatement(b []byte) error {
+	var st struct {
+		PredicateType string `json:"predicateType"`
+		Predicate     struct {
+			// SLSA v0.2.
+			BuildConfig interface{} `json:"buildConfig"`
+			Invocation  struct {
+				Parameters interface{} `json:"parameters"`
+			} `json:"invocation"`
+			// SLSA v1.0.
+			BuildDefinition struct {
+				ExternalParameters struct {
+					Parameters interface{} `json:"parameters"`
+				} `json:"externalParameters"`
+				InternalParameters struct {
+					BuildConfig interface{} `json:"buildConfig"`
+				} `json:"internalParameters"`
+			} `json:"buildDefinition"`
+		} `json:"predicate"`
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	v := make(map[string]interface{})
+	switch st.PredicateType {
+	case slsa.PredicateSLSAProvenance:
+		v["buildConfig"] = st.Predicate.BuildConfig
+		v["parameters"] = st.Predicate.Invocation.Parameters
+	case PredicateSLSAProvenanceV1:
+		v["buildConfig"] = st.Predicate.BuildDefinition.InternalParameters.BuildConfig
+		v["parameters"] = st.Predicate.BuildDefinition.ExternalParameters.Parameters
+	default:
+		return fmt.Errorf("%w: predicate type %q", errorSchemaValidation, st.PredicateType)
+	}
+
+	return s.validate(v, "")
+}
+
+func (s *jsonSchema) validate(v interface{}, path string) error {
+	if s.Const != nil && !reflect.DeepEqual(normalizeConst(s.Const), v) {
+		return fmt.Errorf("%w: %s: expected %v, got %v", errorSchemaValidation, pathOrRoot(path), s.Const, v)
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, o := range s.OneOf {
+			if o.validate(v, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%w: %s: expected exactly one schema of oneOf to match, %d matched",
+				errorSchemaValidation, pathOrRoot(path), matches)
+		}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "null":
+		if v != nil {
+			return typeMismatch(s.Type, v, path)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeMismatch(s.Type, v, path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeMismatch(s.Type, v, path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return typeMismatch(s.Type, v, path)
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != float64(int64(f)) {
+			return typeMismatch(s.Type, v, path)
+		}
+	case "array":
+		l, ok := v.([]interface{})
+		if !ok {
+			return typeMismatch(s.Type, v, path)
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, e := range l {
+			if err := s.Items.validate(e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return typeMismatch(s.Type, v, path)
+		}
+		return s.validateObject(m, path)
+	default:
+		return fmt.Errorf("%w: %s: unsupported type %q", errorSchemaValidation, pathOrRoot(path), s.Type)
+	}
+
+	return nil
+}
+
+func (s *jsonSchema) validateObject(m map[string]interface{}, path string) error {
+	for _, r := range s.Required {
+		if _, exists := m[r]; !exists {
+			return fmt.Errorf("%w: %s: missing required property %q", errorSchemaValidation, pathOrRoot(path), r)
+		}
+	}
+
+	// Iterate in a stable order so errors are deterministic.
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "." + k
+		if ps, exists := s.Properties[k]; exists {
+			if err := ps.validate(m[k], p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch ap := s.AdditionalProperties.(type) {
+		case bool:
+			if !ap {
+				return fmt.Errorf("%w: %s: unexpected property %q", errorSchemaValidation, pathOrRoot(path), k)
+			}
+		case *jsonSchema:
+			if err := ap.validate(m[k], p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizeConst converts a Go constant to the value
+// produced by json.Unmarshal.
+func normalizeConst(c interface{}) interface{} {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return c
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return c
+	}
+	return v
+}
+
+func typeMismatch(expected string, v interface{}, path string) error {
+	return fmt.Errorf("%w: %s: expected %s, got %T", errorSchemaValidation, pathOrRoot(path), expected, v)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return "$" + path
+}
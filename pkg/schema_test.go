@@ -0,0 +1,306 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const buildTypeSchemaPath = "../buildtypes/node/v1/schema.json"
+
+func Test_BuildTypeSchema(t *testing.T) {
+	t.Parallel()
+
+	s, err := BuildTypeSchema()
+	if err != nil {
+		t.Fatalf("BuildTypeSchema: %v", err)
+	}
+
+	checkGolden(t, buildTypeSchemaPath, s)
+}
+
+func Test_validateBuildType(t *testing.T) {
+	t.Parallel()
+
+	validConfig := BuildConfig{
+		Version: buildTypeVersion,
+		Steps: []Step{
+			{
+				Command: []string{"node", "npm", "pack"},
+				Env:     []string{},
+			},
+		},
+	}
+	validParameters := Parameters{
+		Version:   buildTypeVersion,
+		EventName: "push",
+		Ref:       "refs/tags/v1.2.3",
+		RefType:   "tag",
+		SHA1:      "6113728f27ae82c7b1a177c8d03f9e96e0adf246",
+	}
+
+	tests := []struct {
+		name        string
+		buildConfig interface{}
+		parameters  interface{}
+		expected    error
+	}{
+		{
+			name:        "valid",
+			buildConfig: validConfig,
+			parameters:  validParameters,
+			expected:    nil,
+		},
+		{
+			name: "unsupported version",
+			buildConfig: BuildConfig{
+				Version: buildTypeVersion + 1,
+				Steps:   validConfig.Steps,
+			},
+			parameters: validParameters,
+			expected:   errorSchemaValidation,
+		},
+		{
+			name: "null env",
+			buildConfig: BuildConfig{
+				Version: buildTypeVersion,
+				Steps: []Step{
+					{
+						Command: []string{"node", "npm", "pack"},
+					},
+				},
+			},
+			parameters: validParameters,
+			expected:   errorSchemaValidation,
+		},
+		{
+			name:        "unexpected property",
+			buildConfig: validConfig,
+			parameters: map[string]interface{}{
+				"version": buildTypeVersion,
+				"secret":  "value",
+			},
+			expected: errorSchemaValidation,
+		},
+		{
+			name:        "missing parameters",
+			buildConfig: validConfig,
+			parameters:  map[string]interface{}{},
+			expected:    errorSchemaValidation,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateBuildType(tt.buildConfig, tt.parameters)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+// loadBuildTypeSchema reads the published schema of BuildTypeNode.
+func loadBuildTypeSchema(t *testing.T) *jsonSchema {
+	t.Helper()
+
+	b, err := os.ReadFile(buildTypeSchemaPath)
+	if err != nil {
+		t.Fatalf("os.ReadFile: %v", err)
+	}
+
+	var s jsonSchema
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	return &s
+}
+
+func Test_validateStatement(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		golden   string
+		edit     func(predicate map[string]interface{})
+		expected error
+	}{
+		{
+			name:     "valid v0.2",
+			golden:   "./testdata/provenance-v0.2.golden.json",
+			expected: nil,
+		},
+		{
+			name:     "valid v1",
+			golden:   "./testdata/provenance-v1.golden.json",
+			expected: nil,
+		},
+		{
+			name:   "null event payload",
+			golden: "./testdata/provenance-v0.2.golden.json",
+			edit: func(predicate map[string]interface{}) {
+				parameters := predicate["invocation"].(map[string]interface{})["parameters"].(map[string]interface{})
+				parameters["event_payload"] = nil
+			},
+			expected: nil,
+		},
+		{
+			name:   "unredacted event payload",
+			golden: "./testdata/provenance-v0.2.golden.json",
+			edit: func(predicate map[string]interface{}) {
+				parameters := predicate["invocation"].(map[string]interface{})["parameters"].(map[string]interface{})
+				payload := parameters["event_payload"].(map[string]interface{})
+				payload["commits"] = []interface{}{}
+			},
+			expected: errorSchemaValidation,
+		},
+		{
+			name:   "incomplete event payload",
+			golden: "./testdata/provenance-v1.golden.json",
+			edit: func(predicate map[string]interface{}) {
+				external := predicate["buildDefinition"].(map[string]interface{})["externalParameters"].(map[string]interface{})
+				payload := external["parameters"].(map[string]interface{})["event_payload"].(map[string]interface{})
+				delete(payload, "sender")
+			},
+			expected: errorSchemaValidation,
+		},
+		{
+			name:   "unsupported event payload",
+			golden: "./testdata/provenance-v1.golden.json",
+			edit: func(predicate map[string]interface{}) {
+				external := predicate["buildDefinition"].(map[string]interface{})["externalParameters"].(map[string]interface{})
+				parameters := external["parameters"].(map[string]interface{})
+				parameters["event_payload"] = map[string]interface{}{"action": "created"}
+			},
+			expected: errorSchemaValidation,
+		},
+		{
+			name:   "missing buildConfig",
+			golden: "./testdata/provenance-v1.golden.json",
+			edit: func(predicate map[string]interface{}) {
+				internal := predicate["buildDefinition"].(map[string]interface{})["internalParameters"].(map[string]interface{})
+				delete(internal, "buildConfig")
+			},
+			expected: errorSchemaValidation,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := os.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatalf("os.ReadFile: %v", err)
+			}
+
+			if tt.edit != nil {
+				var st map[string]interface{}
+				if err := json.Unmarshal(b, &st); err != nil {
+					t.Fatalf("json.Unmarshal: %v", err)
+				}
+				tt.edit(st["predicate"].(map[string]interface{}))
+				if b, err = json.Marshal(st); err != nil {
+					t.Fatalf("json.Marshal: %v", err)
+				}
+			}
+
+			err = loadBuildTypeSchema(t).validateStatement(b)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
+
+func Test_validateStatement_eventPayloads(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		eventName string
+		path      string
+	}{
+		{
+			name:      "push tag",
+			eventName: "push",
+			path:      "./testdata/push_payload_tag.json",
+		},
+		{
+			name:      "push branch",
+			eventName: "push",
+			path:      "./testdata/push_payload_notag.json",
+		},
+		{
+			name:      "release",
+			eventName: "release",
+			path:      "./testdata/release_payload.json",
+		},
+		{
+			name:      "workflow_dispatch",
+			eventName: "workflow_dispatch",
+			path:      "./testdata/workflow_dispatch_payload.json",
+		},
+		{
+			name:      "pull_request",
+			eventName: "pull_request",
+			path:      "./testdata/pull_request_payload.json",
+		},
+		{
+			name:      "unsupported event",
+			eventName: "issues",
+			path:      "./testdata/release_payload.json",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			payload, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatalf("os.ReadFile: %v", err)
+			}
+
+			for _, policy := range []string{RedactionMinimal, RedactionStandard} {
+				for _, version := range []string{PredicateVersionV02, PredicateVersionV1} {
+					p := testProvenance(t)
+					if err := p.setEventPayload(tt.eventName, payload, policy); err != nil {
+						t.Fatalf("setEventPayload: %v", err)
+					}
+
+					att, err := p.statement(version)
+					if err != nil {
+						t.Fatalf("statement: %v", err)
+					}
+					b, err := json.Marshal(att)
+					if err != nil {
+						t.Fatalf("json.Marshal: %v", err)
+					}
+
+					if err := loadBuildTypeSchema(t).validateStatement(b); err != nil {
+						t.Errorf("%s %s: validateStatement: %v", policy, version, err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	errorInvalidInstallScriptPolicy = errors.New("invalid install script policy")
+	errorInstallScriptNotAllowed    = errors.New("install script not allowed")
+)
+
+// InstallScriptPolicy lists the dependencies allowed to have install
+// scripts, i.e. preinstall, install or postinstall scripts, which run
+// when the dependencies are installed. It is defined in the releaser config.
+type InstallScriptPolicy struct {
+	// Run runs the install scripts of the dependencies.
+	// By default, they are ignored.
+	Run bool `yaml:"run"`
+	// Allow are the names of the packages, as path.Match patterns,
+	// e.g. esbuild or @scope/*, optionally followed by a range of
+	// versions, e.g. esbuild@^0.14.0.
+	Allow []string `yaml:"allow"`
+}
+
+// InstallScripts is the audit of the install scripts of
+// the dependencies, recorded by the builder.
+type InstallScripts struct {
+	// IgnoreScripts is true if the dependencies were
+	// installed with --ignore-scripts.
+	IgnoreScripts bool `json:"ignoreScripts"`
+	// Packages are the dependencies with install scripts,
+	// as name@version, sorted. It is nil if the lockfile
+	// does not record install scripts.
+	Packages []string `json:"packages"`
+}
+
+func (p *InstallScriptPolicy) validate() error {
+	for _, a := range p.Allow {
+		name, r := splitAllowedPackage(a)
+		if _, err := path.Match(name, ""); err != nil {
+			return fmt.Errorf("%w: %q: %v", errorInvalidInstallScriptPolicy, a, err)
+		}
+		if r == "" {
+			continue
+		}
+		if _, err := matchVersionRange(r, semver{}); err != nil {
+			return fmt.Errorf("%w: %q: %v", errorInvalidInstallScriptPolicy, a, err)
+		}
+	}
+	return nil
+}
+
+// splitAllowedPackage splits an entry of the allowlist into
+// a pattern of names and an optional range of versions.
+func splitAllowedPackage(a string) (string, string) {
+	if name, r, ok := splitDescriptor(a); ok {
+		return name, r
+	}
+	return a, ""
+}
+
+// allows returns true if the version of the package may have install scripts.
+func (p *InstallScriptPolicy) allows(name, version string) bool {
+	for _, a := range p.Allow {
+		pattern, r := splitAllowedPackage(a)
+		if ok, _ := path.Match(pattern, name); !ok {
+			continue
+		}
+		if r == "" {
+			return true
+		}
+
+		v, err := parseSemver(version)
+		if err != nil {
+			continue
+		}
+		if ok, _ := matchVersionRange(r, *v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// auditInstallScripts returns the packages of the lockfile with install
+// scripts, as name@version, and fails if one of them is not allowed.
+func auditInstallScripts(l *Lockfile, policy *InstallScriptPolicy) ([]string, error) {
+	seen := make(map[string]bool)
+	packages := []string{}
+	var denied []string
+	for _, p := range l.Packages {
+		if !p.HasInstallScript {
+			continue
+		}
+
+		id := fmt.Sprintf("%s@%s", p.Name, p.Version)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		packages = append(packages, id)
+		if !policy.allows(p.Name, p.Version) {
+			denied = append(denied, id)
+		}
+	}
+	sort.Strings(packages)
+	sort.Strings(denied)
+
+	if len(denied) > 0 {
+		return nil, fmt.Errorf("%w: %s", errorInstallScriptNotAllowed, strings.Join(denied, ", "))
+	}
+	return packages, nil
+}
@@ -0,0 +1,142 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_auditInstallScripts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dir      string
+		allow    []string
+		packages []string
+		expected error
+	}{
+		{
+			name:     "npm no allowlist",
+			dir:      "./testdata/lockfile/install-scripts",
+			expected: errorInstallScriptNotAllowed,
+		},
+		{
+			name:     "npm allowed",
+			dir:      "./testdata/lockfile/install-scripts",
+			allow:    []string{"esbuild", "@scope/*"},
+			packages: []string{"@scope/native@1.2.0", "esbuild@0.14.38"},
+		},
+		{
+			name:     "npm allowed version",
+			dir:      "./testdata/lockfile/install-scripts",
+			allow:    []string{"esbuild@^0.14.0", "@scope/native@1.2.0"},
+			packages: []string{"@scope/native@1.2.0", "esbuild@0.14.38"},
+		},
+		{
+			name:     "npm version not allowed",
+			dir:      "./testdata/lockfile/install-scripts",
+			allow:    []string{"esbuild@^0.15.0", "@scope/native"},
+			expected: errorInstallScriptNotAllowed,
+		},
+		{
+			name:     "npm scope not allowed",
+			dir:      "./testdata/lockfile/install-scripts",
+			allow:    []string{"esbuild", "@other/*"},
+			expected: errorInstallScriptNotAllowed,
+		},
+		{
+			name:     "pnpm allowed",
+			dir:      "./testdata/lockfile/install-scripts-pnpm",
+			allow:    []string{"esbuild", "@scope/native"},
+			packages: []string{"@scope/native@1.2.0", "esbuild@0.14.38"},
+		},
+		{
+			name:     "pnpm not allowed",
+			dir:      "./testdata/lockfile/install-scripts-pnpm",
+			allow:    []string{"esbuild"},
+			expected: errorInstallScriptNotAllowed,
+		},
+		{
+			name:     "no install scripts",
+			dir:      "./testdata/lockfile/npm-v3",
+			packages: []string{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := lockfileFromDir(tt.dir)
+			if err != nil {
+				t.Fatalf("lockfileFromDir: %v", err)
+			}
+			if !l.installScripts {
+				t.Fatalf("install scripts not recorded")
+			}
+
+			packages, err := auditInstallScripts(l, &InstallScriptPolicy{Allow: tt.allow})
+			if !errCmp(err, tt.expected) {
+				t.Fatalf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tt.packages, packages); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func Test_InstallScriptPolicy_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		allow    []string
+		expected error
+	}{
+		{
+			name:  "names and ranges",
+			allow: []string{"esbuild", "@scope/*", "esbuild@^0.14.0", "@scope/a@1.x || 2.x"},
+		},
+		{
+			name:     "invalid pattern",
+			allow:    []string{"[esbuild"},
+			expected: errorInvalidInstallScriptPolicy,
+		},
+		{
+			name:     "invalid range",
+			allow:    []string{"esbuild@>>0.14.0"},
+			expected: errorInvalidInstallScriptPolicy,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := InstallScriptPolicy{Allow: tt.allow}
+			if err := p.validate(); !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}
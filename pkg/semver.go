@@ -0,0 +1,322 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	errorInvalidVersion      = errors.New("invalid version")
+	errorInvalidVersionRange = errors.New("invalid version range")
+)
+
+// semver is a semantic version. Build metadata is ignored.
+// See https://semver.org.
+type semver struct {
+	major, minor, patch int
+	prerelease          string
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	return s
+}
+
+// parseSemver parses a full version, e.g. v16.15.0 or 1.2.3-beta.1.
+func parseSemver(s string) (*semver, error) {
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	if p.parts != 3 {
+		return nil, fmt.Errorf("%w: %q", errorInvalidVersion, s)
+	}
+	return &p.v, nil
+}
+
+// partialVersion is a version in a range, where trailing
+// components may be omitted or replaced by x, X or *.
+type partialVersion struct {
+	v semver
+	// parts is the number of components set.
+	parts int
+}
+
+func parsePartialVersion(s string) (*partialVersion, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "="), "v")
+	// Build metadata does not affect precedence.
+	s = strings.SplitN(s, "+", 2)[0]
+
+	var p partialVersion
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) == 2 {
+		p.v.prerelease = parts[1]
+	}
+
+	components := strings.Split(parts[0], ".")
+	if len(components) > 3 {
+		return nil, fmt.Errorf("%w: %q", errorInvalidVersion, s)
+	}
+
+	values := []*int{&p.v.major, &p.v.minor, &p.v.patch}
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			break
+		}
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %q", errorInvalidVersion, s)
+		}
+		*values[i] = n
+		p.parts++
+	}
+
+	// A prerelease is only valid on a full version.
+	if p.v.prerelease != "" && p.parts != 3 {
+		return nil, fmt.Errorf("%w: %q", errorInvalidVersion, s)
+	}
+	return &p, nil
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.prerelease, o.prerelease)
+}
+
+// comparePrerelease compares prerelease identifiers: a version
+// without a prerelease has a higher precedence.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr == nil && berr == nil:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case aerr == nil:
+			// Numeric identifiers have a lower precedence.
+			return -1
+		case berr == nil:
+			return 1
+		case as[i] < bs[i]:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparator is a primitive constraint on a version.
+type comparator struct {
+	op string
+	v  semver
+}
+
+func (c comparator) matches(v semver) bool {
+	r := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	default:
+		return r == 0
+	}
+}
+
+// Spaces between an operator and its version are allowed, e.g. ">= 16".
+var operatorSpaces = regexp.MustCompile(`([<>=~^]+)\s+`)
+
+// matchVersionRange reports whether v satisfies the range r, using the
+// syntax of the engines field of package.json, e.g. ">=14 <19 || ^20.1".
+// See https://github.com/npm/node-semver#ranges.
+func matchVersionRange(r string, v semver) (bool, error) {
+	for _, set := range strings.Split(r, "||") {
+		comparators, err := parseComparatorSet(set)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q: %v", errorInvalidVersionRange, r, err)
+		}
+
+		ok := true
+		for _, c := range comparators {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func parseComparatorSet(set string) ([]comparator, error) {
+	set = strings.TrimSpace(set)
+
+	// Hyphen range, e.g. 14 - 16.2.
+	if parts := strings.Split(set, " - "); len(parts) == 2 {
+		lower, err := expandComparator(">=", strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		upper, err := expandComparator("<=", strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		return append(lower, upper...), nil
+	}
+
+	var comparators []comparator
+	for _, f := range strings.Fields(operatorSpaces.ReplaceAllString(set, "$1")) {
+		i := strings.IndexFunc(f, func(r rune) bool {
+			return !strings.ContainsRune("<>=~^", r)
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", errorInvalidVersion, f)
+		}
+		c, err := expandComparator(f[:i], f[i:])
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, c...)
+	}
+	return comparators, nil
+}
+
+// expandComparator expands an operator and a partial version
+// into primitive comparators.
+func expandComparator(op, version string) ([]comparator, error) {
+	p, err := parsePartialVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	v := p.v
+
+	// The first version excluded by the range when the
+	// last component set is incremented.
+	next := v
+	switch p.parts {
+	case 0:
+		next = semver{}
+	case 1:
+		next = semver{major: v.major + 1}
+	case 2:
+		next = semver{major: v.major, minor: v.minor + 1}
+	}
+
+	switch op {
+	case "", "=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		if p.parts == 3 {
+			return []comparator{{"=", v}}, nil
+		}
+		return []comparator{{">=", v}, {"<", next}}, nil
+	case ">=":
+		return []comparator{{">=", v}}, nil
+	case "<":
+		if p.parts == 0 {
+			// Nothing is lower than *.
+			return []comparator{{"<", semver{}}, {">", semver{}}}, nil
+		}
+		return []comparator{{"<", v}}, nil
+	case ">":
+		if p.parts == 0 {
+			return []comparator{{"<", semver{}}, {">", semver{}}}, nil
+		}
+		if p.parts == 3 {
+			return []comparator{{">", v}}, nil
+		}
+		return []comparator{{">=", next}}, nil
+	case "<=":
+		if p.parts == 0 {
+			return nil, nil
+		}
+		if p.parts == 3 {
+			return []comparator{{"<=", v}}, nil
+		}
+		return []comparator{{"<", next}}, nil
+	case "~":
+		switch p.parts {
+		case 0:
+			return nil, nil
+		case 1:
+			return []comparator{{">=", v}, {"<", next}}, nil
+		default:
+			return []comparator{{">=", v}, {"<", semver{major: v.major, minor: v.minor + 1}}}, nil
+		}
+	case "^":
+		// Changes that do not modify the left-most non-zero component.
+		var upper semver
+		switch {
+		case p.parts == 0:
+			return nil, nil
+		case v.major > 0 || p.parts == 1:
+			upper = semver{major: v.major + 1}
+		case v.minor > 0 || p.parts == 2:
+			upper = semver{minor: v.minor + 1}
+		default:
+			upper = semver{patch: v.patch + 1}
+		}
+		return []comparator{{">=", v}, {"<", upper}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseSemver(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		version string
+		semver  *semver
+		err     error
+	}{
+		{
+			name:    "node version",
+			version: "v16.15.0",
+			semver:  &semver{major: 16, minor: 15},
+		},
+		{
+			name:    "prerelease and build",
+			version: "1.2.3-beta.1+abc",
+			semver:  &semver{major: 1, minor: 2, patch: 3, prerelease: "beta.1"},
+		},
+		{
+			name:    "partial",
+			version: "16.15",
+			err:     errorInvalidVersion,
+		},
+		{
+			name:    "not a number",
+			version: "v16.x.0",
+			err:     errorInvalidVersion,
+		},
+		{
+			name:    "alias",
+			version: "lts/gallium",
+			err:     errorInvalidVersion,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := parseSemver(tt.version)
+			if !errCmp(err, tt.err) {
+				t.Errorf(cmp.Diff(err, tt.err))
+			}
+			if err != nil {
+				return
+			}
+			if !cmp.Equal(v, tt.semver, cmp.AllowUnexported(semver{})) {
+				t.Errorf(cmp.Diff(v, tt.semver, cmp.AllowUnexported(semver{})))
+			}
+		})
+	}
+}
+
+func Test_matchVersionRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		r       string
+		version string
+		match   bool
+		err     error
+	}{
+		{r: "*", version: "16.15.0", match: true},
+		{r: "", version: "16.15.0", match: true},
+		{r: "16", version: "16.15.0", match: true},
+		{r: "16", version: "17.0.0", match: false},
+		{r: "16.x", version: "16.15.0", match: true},
+		{r: "16.14", version: "16.15.0", match: false},
+		{r: "v16.15.0", version: "16.15.0", match: true},
+		{r: "=16.15.1", version: "16.15.0", match: false},
+		{r: ">=16", version: "16.0.0", match: true},
+		{r: ">= 16", version: "15.14.0", match: false},
+		{r: ">16", version: "16.15.0", match: false},
+		{r: ">16", version: "17.0.0", match: true},
+		{r: ">16.15.0", version: "16.15.1", match: true},
+		{r: "<16", version: "15.14.0", match: true},
+		{r: "<16", version: "16.0.0", match: false},
+		{r: "<=16", version: "16.15.0", match: true},
+		{r: "<=16.14", version: "16.15.0", match: false},
+		{r: "^16.14.0", version: "16.15.0", match: true},
+		{r: "^16.14.0", version: "17.0.0", match: false},
+		{r: "^0.2.3", version: "0.2.9", match: true},
+		{r: "^0.2.3", version: "0.3.0", match: false},
+		{r: "^0.0.3", version: "0.0.4", match: false},
+		{r: "~16.14.0", version: "16.14.2", match: true},
+		{r: "~16.14.0", version: "16.15.0", match: false},
+		{r: "~16", version: "16.15.0", match: true},
+		{r: ">=14 <16", version: "16.15.0", match: false},
+		{r: ">=14 <16 || >=18", version: "18.1.0", match: true},
+		{r: "^14.17.0 || ^16.13.0", version: "16.15.0", match: true},
+		{r: "14 - 16", version: "16.15.0", match: true},
+		{r: "14 - 16.14", version: "16.15.0", match: false},
+		{r: ">=16.0.0", version: "16.0.0-rc.1", match: false},
+		{r: "!16", version: "16.15.0", err: errorInvalidVersionRange},
+		{r: ">=sixteen", version: "16.15.0", err: errorInvalidVersionRange},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.r+" "+tt.version, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := parseSemver(tt.version)
+			if err != nil {
+				t.Fatalf("parseSemver: %v", err)
+			}
+
+			match, err := matchVersionRange(tt.r, *v)
+			if !errCmp(err, tt.err) {
+				t.Errorf(cmp.Diff(err, tt.err))
+			}
+			if match != tt.match {
+				t.Errorf("matchVersionRange(%q, %s) = %v, expected %v", tt.r, tt.version, match, tt.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorInvalidChecksums  = errors.New("invalid checksums")
+	errorInvalidSubject    = errors.New("invalid subject")
+	errorConflictingDigest = errors.New("conflicting digests for subject")
+	errorNoSubject         = errors.New("no subject")
+)
+
+// NewSubject returns a subject with the given digests.
+func NewSubject(name string, digests slsa.DigestSet) intoto.Subject {
+	return intoto.Subject{
+		Name:   name,
+		Digest: digests,
+	}
+}
+
+func subjectsFromChecksums(b []byte) ([]intoto.Subject, error) {
+	var subjects []intoto.Subject
+
+	// Format is the output of `sha256sum` or `sha512sum`: one `<digest> <name>`
+	// per line, where the name is prefixed by '*' in binary mode.
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: line %d: %s", errorInvalidChecksums, i, line)
+		}
+
+		alg, digest, err := parseDigest(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", errorInvalidChecksums, i, err)
+		}
+
+		name := strings.TrimPrefix(strings.TrimLeft(parts[1], " "), "*")
+		subjects = append(subjects, NewSubject(name, slsa.DigestSet{alg: digest}))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return subjects, nil
+}
+
+// SubjectsFromChecksumsFile reads the subjects from a file
+// in the format output by `sha256sum` or `sha512sum`.
+func SubjectsFromChecksumsFile(pathfn string) ([]intoto.Subject, error) {
+	b, err := os.ReadFile(pathfn)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	return subjectsFromChecksums(b)
+}
+
+// validateSubjects verifies the name and digests of each subject.
+// Digests of duplicate subjects are merged, and a subject listed
+// with different digests for the same algorithm is an error.
+func validateSubjects(subjects []intoto.Subject) ([]intoto.Subject, error) {
+	if len(subjects) == 0 {
+		return nil, errorNoSubject
+	}
+
+	var res []intoto.Subject
+	index := make(map[string]int)
+	for _, s := range subjects {
+		if err := verifyProvenanceName(s.Name); err != nil {
+			return nil, fmt.Errorf("%w: %v", errorInvalidSubject, err)
+		}
+
+		if err := validateDigestSet(s.Digest); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errorInvalidSubject, s.Name, err)
+		}
+
+		i, exists := index[s.Name]
+		if !exists {
+			index[s.Name] = len(res)
+			res = append(res, NewSubject(s.Name, copyDigestSet(s.Digest)))
+			continue
+		}
+
+		for alg, value := range s.Digest {
+			if v, exists := res[i].Digest[alg]; exists && v != value {
+				return nil, fmt.Errorf("%w: %s", errorConflictingDigest, s.Name)
+			}
+			res[i].Digest[alg] = value
+		}
+	}
+
+	return res, nil
+}
+
+// PackageIntegrity returns the SRI string of the subject named name,
+// from its sha512 digest as the npm registry records it. It is empty
+// if no subject has this name and a sha512 digest.
+func PackageIntegrity(subjects []intoto.Subject, name string) string {
+	for _, s := range subjects {
+		if s.Name != name {
+			continue
+		}
+		if v, exists := s.Digest["sha512"]; exists {
+			return IntegrityFromDigestSet(slsa.DigestSet{"sha512": v})
+		}
+	}
+	return ""
+}
+
+func copyDigestSet(d slsa.DigestSet) slsa.DigestSet {
+	c := make(slsa.DigestSet, len(d))
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,253 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+func Test_SubjectsFromChecksumsFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected struct {
+			err   error
+			names []string
+		}
+	}{
+		{
+			name: "valid checksums",
+			path: "./testdata/checksums-valid.txt",
+			expected: struct {
+				err   error
+				names []string
+			}{
+				names: []string{
+					"slsa-github-generator-node-test-1.0.1.tgz",
+					"slsa-github-generator-node-test-linux-x64",
+					"slsa-github-generator-node-test-1.0.1-src.tar.gz",
+				},
+			},
+		},
+		{
+			name: "invalid name",
+			path: "./testdata/checksums-invalid-name.txt",
+			expected: struct {
+				err   error
+				names []string
+			}{
+				err: errorInvalidSubject,
+			},
+		},
+		{
+			name: "invalid digest",
+			path: "./testdata/checksums-invalid-digest.txt",
+			expected: struct {
+				err   error
+				names []string
+			}{
+				err: errorInvalidChecksums,
+			},
+		},
+		{
+			name: "invalid format",
+			path: "./testdata/checksums-invalid-format.txt",
+			expected: struct {
+				err   error
+				names []string
+			}{
+				err: errorInvalidChecksums,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := SubjectsFromChecksumsFile(tt.path)
+			if err == nil {
+				s, err = validateSubjects(s)
+			}
+			if !errCmp(err, tt.expected.err) {
+				t.Errorf(cmp.Diff(err, tt.expected.err))
+			}
+			if err != nil {
+				return
+			}
+
+			var names []string
+			for _, e := range s {
+				names = append(names, e.Name)
+			}
+			if !cmp.Equal(names, tt.expected.names) {
+				t.Errorf(cmp.Diff(names, tt.expected.names))
+			}
+		})
+	}
+}
+
+func Test_validateSubjects(t *testing.T) {
+	t.Parallel()
+
+	const (
+		digest1    = "0ae7e4fa71686538440012ee36a2634dbaa19df2dd16a466f52411fb348bbc4e"
+		digest2    = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+		sha1Digest = "d6525c840a62b398424a78d792f457477135d0cf"
+	)
+
+	tests := []struct {
+		name     string
+		subjects []intoto.Subject
+		expected struct {
+			err      error
+			subjects []intoto.Subject
+		}
+	}{
+		{
+			name:     "duplicate subjects",
+			subjects: []intoto.Subject{NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1}), NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1})},
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				subjects: []intoto.Subject{NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1})},
+			},
+		},
+		{
+			name: "merged digests",
+			subjects: []intoto.Subject{
+				NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1}),
+				NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1, "sha1": sha1Digest}),
+			},
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				subjects: []intoto.Subject{
+					NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1, "sha1": sha1Digest}),
+				},
+			},
+		},
+		{
+			name:     "sha1 only",
+			subjects: []intoto.Subject{NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha1": sha1Digest})},
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				err: errorInvalidSubject,
+			},
+		},
+		{
+			name:     "conflicting digests",
+			subjects: []intoto.Subject{NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest1}), NewSubject("foo-1.0.0.tgz", slsa.DigestSet{"sha256": digest2})},
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				err: errorConflictingDigest,
+			},
+		},
+		{
+			name: "no subject",
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				err: errorNoSubject,
+			},
+		},
+		{
+			name:     "path name",
+			subjects: []intoto.Subject{NewSubject("..", slsa.DigestSet{"sha256": digest1})},
+			expected: struct {
+				err      error
+				subjects []intoto.Subject
+			}{
+				err: errorInvalidSubject,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := validateSubjects(tt.subjects)
+			if !errCmp(err, tt.expected.err) {
+				t.Errorf(cmp.Diff(err, tt.expected.err))
+			}
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(s, tt.expected.subjects) {
+				t.Errorf(cmp.Diff(s, tt.expected.subjects))
+			}
+		})
+	}
+}
+
+func Test_PackageIntegrity(t *testing.T) {
+	t.Parallel()
+
+	subjects := []intoto.Subject{
+		NewSubject("other-1.0.0.tgz", slsa.DigestSet{"sha512": helloSHA512}),
+		NewSubject("pkg-1.0.0.tgz", slsa.DigestSet{
+			"sha1":   helloSHA1,
+			"sha256": helloSHA256,
+			"sha512": helloSHA512,
+		}),
+		NewSubject("sha256-only-1.0.0.tgz", slsa.DigestSet{"sha256": helloSHA256}),
+	}
+
+	tests := []struct {
+		name     string
+		subject  string
+		expected string
+	}{
+		{
+			name:     "sha512 only",
+			subject:  "pkg-1.0.0.tgz",
+			expected: helloSRI512,
+		},
+		{
+			name:    "no sha512 digest",
+			subject: "sha256-only-1.0.0.tgz",
+		},
+		{
+			name:    "no subject",
+			subject: "missing-1.0.0.tgz",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := PackageIntegrity(subjects, tt.subject)
+			if !cmp.Equal(r, tt.expected) {
+				t.Errorf(cmp.Diff(r, tt.expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorIntegrityMismatch  = errors.New("integrity mismatch")
+	errorOfflineUnsupported = errors.New("package cannot be installed offline")
+)
+
+// InstallConfig configures the install of the dependencies.
+// It is defined in the releaser config.
+type InstallConfig struct {
+	// Offline installs the dependencies from a cache of tarballs
+	// downloaded and verified by the builder, without network access.
+	Offline bool `yaml:"offline"`
+}
+
+// InstallResult describes the install of the dependencies,
+// recorded by the builder.
+type InstallResult struct {
+	Offline bool `json:"offline"`
+	// Tarballs are the tarballs the packages were installed from,
+	// identified by the URL they were downloaded from and their digests.
+	Tarballs []ResourceDescriptor `json:"tarballs,omitempty"`
+	// CacheCommand is the command that added the tarballs to the npm cache
+	// before the install. It is recorded as the first step of the provenance.
+	CacheCommand []string `json:"cacheCommand,omitempty"`
+}
+
+// tarballCacheDir is the directory of the tarballs of the offline install.
+// Tarballs already in the directory are verified and reused.
+func tarballCacheDir() string {
+	return filepath.Join(os.TempDir(), "slsa-github-generator-node", "tarballs")
+}
+
+// fetchFunc returns the content of the tarball at a URL.
+type fetchFunc func(url string) (io.ReadCloser, error)
+
+func httpFetch(url string) (io.ReadCloser, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("http.Get: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("http.Get: %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// offlinePackages returns the packages of the lockfile downloaded from
+// a remote location, indexed by URL. Each package must be an http(s)
+// tarball with an integrity, so that it can be verified before the install.
+func offlinePackages(l *Lockfile) (map[string]*LockedPackage, error) {
+	res := make(map[string]*LockedPackage)
+	for key, p := range l.Packages {
+		// Links and packages of the workspace are part of the source.
+		if p.Resolved == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(p.Resolved, "https://") && !strings.HasPrefix(p.Resolved, "http://") {
+			return nil, fmt.Errorf("%w: %s: %s is not a tarball URL", errorOfflineUnsupported, key, p.Resolved)
+		}
+		if p.Integrity == "" {
+			return nil, fmt.Errorf("%w: %s: missing integrity", errorOfflineUnsupported, key)
+		}
+		res[p.Resolved] = p
+	}
+	return res, nil
+}
+
+// buildTarballCache downloads the tarballs of the packages of the lockfile
+// into dir, and verifies each of them against its integrity. It returns
+// the tarballs sorted by URL, and the paths of their files. The name of
+// a file is derived from the integrity, so that a tarball already in dir
+// is only reused if it verifies.
+func buildTarballCache(l *Lockfile, dir string, fetch fetchFunc) ([]ResourceDescriptor, []string, error) {
+	packages, err := offlinePackages(l)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	urls := make([]string, 0, len(packages))
+	for url := range packages {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	tarballs := make([]ResourceDescriptor, 0, len(urls))
+	paths := make([]string, 0, len(urls))
+	for _, url := range urls {
+		p := packages[url]
+		integrity, err := DigestSetFromString(p.Integrity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", errorInvalidLockfile, url, err)
+		}
+
+		fn := filepath.Join(dir, tarballFilename(integrity))
+		digests, err := fileDigests(fn)
+		if err != nil || verifyIntegrity(digests, integrity) != nil {
+			if digests, err = downloadTarball(fetch, url, fn, integrity); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		tarballs = append(tarballs, ResourceDescriptor{
+			URI:    url,
+			Digest: digests,
+			Name:   p.Name,
+			Annotations: map[string]interface{}{
+				"version": p.Version,
+			},
+		})
+		paths = append(paths, fn)
+	}
+	return tarballs, paths, nil
+}
+
+// tarballFilename returns the name of the tarball with the
+// strongest digest of integrity.
+func tarballFilename(integrity slsa.DigestSet) string {
+	for _, alg := range digestAlgorithms {
+		if v, exists := integrity[alg]; exists {
+			return fmt.Sprintf("%s-%s.tgz", alg, v)
+		}
+	}
+	return ""
+}
+
+// downloadTarball downloads the tarball at url to fn. The tarball
+// is only written to fn once it has been verified.
+func downloadTarball(fetch fetchFunc, url, fn string, integrity slsa.DigestSet) (slsa.DigestSet, error) {
+	body, err := fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(fn), ".download-*")
+	if err != nil {
+		return nil, fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("io.Copy: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("os.File.Close: %w", err)
+	}
+
+	digests, err := fileDigests(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIntegrity(digests, integrity); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return nil, fmt.Errorf("os.Rename: %w", err)
+	}
+	return digests, nil
+}
+
+// verifyIntegrity verifies that the digests of a tarball match
+// all the digests of the integrity recorded in the lockfile.
+func verifyIntegrity(digests, integrity slsa.DigestSet) error {
+	for alg, v := range integrity {
+		if digests[alg] != v {
+			return fmt.Errorf("%w: %s", errorIntegrityMismatch, alg)
+		}
+	}
+	return nil
+}
+
+// cacheAddCommand returns the command that adds the tarballs
+// to the npm cache used by the offline install.
+func (b *NodeBuild) cacheAddCommand(paths []string) []string {
+	return append([]string{b.node, b.npm, "cache", "add", "--cache", npmCacheDir(), "--offline"}, paths...)
+}
+
+// isCacheAddCommand returns true if com has the shape of the commands
+// returned by cacheAddCommand.
+func isCacheAddCommand(com []string) bool {
+	if len(com) < 8 {
+		return false
+	}
+	return com[2] == "cache" && com[3] == "add" &&
+		com[4] == "--cache" && com[6] == "--offline"
+}
+
+// withTarballDigests returns the dependencies with the digests of
+// the tarballs they were installed from.
+func (r *InstallResult) withTarballDigests(deps []ResourceDescriptor) []ResourceDescriptor {
+	digests := make(map[string]slsa.DigestSet)
+	for _, t := range r.Tarballs {
+		digests[t.URI] = t.Digest
+	}
+
+	res := make([]ResourceDescriptor, 0, len(deps))
+	for _, d := range deps {
+		if t, exists := digests[d.URI]; exists {
+			d.Digest = copyDigestSet(d.Digest)
+			for alg, v := range t {
+				d.Digest[alg] = v
+			}
+		}
+		res = append(res, d)
+	}
+	return res
+}
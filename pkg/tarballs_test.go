@@ -0,0 +1,284 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	tarballA = []byte("content of a")
+	tarballB = []byte("content of b")
+)
+
+func integrityOf(b []byte) string {
+	return IntegrityFromDigestSet(slsa.DigestSet{"sha512": bytesDigests(b)["sha512"]})
+}
+
+// testFetch serves the tarballs of the registry and counts the downloads.
+func testFetch(registry map[string][]byte, downloads map[string]int) fetchFunc {
+	return func(url string) (io.ReadCloser, error) {
+		b, exists := registry[url]
+		if !exists {
+			return nil, errors.New("not found")
+		}
+		downloads[url]++
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+func Test_buildTarballCache(t *testing.T) {
+	t.Parallel()
+
+	registry := map[string][]byte{
+		"https://registry.npmjs.org/a/-/a-1.0.0.tgz": tarballA,
+		"https://registry.npmjs.org/b/-/b-2.0.0.tgz": tarballB,
+	}
+
+	tests := []struct {
+		name     string
+		packages map[string]*LockedPackage
+		expected error
+	}{
+		{
+			name: "verified",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Integrity: integrityOf(tarballA),
+				},
+				"node_modules/c/node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Integrity: integrityOf(tarballA),
+				},
+				"node_modules/b": {
+					Name: "b", Version: "2.0.0",
+					Resolved:  "https://registry.npmjs.org/b/-/b-2.0.0.tgz",
+					Integrity: integrityOf(tarballB),
+				},
+				"node_modules/e": {
+					Name: "e", Version: "0.0.1",
+					Link: "packages/e",
+				},
+			},
+		},
+		{
+			name: "integrity mismatch",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Integrity: integrityOf(tarballB),
+				},
+			},
+			expected: errorIntegrityMismatch,
+		},
+		{
+			name: "missing integrity",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved: "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+				},
+			},
+			expected: errorOfflineUnsupported,
+		},
+		{
+			name: "git dependency",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved: "git+ssh://git@github.com/org/a.git#0123456789abcdef0123456789abcdef01234567",
+				},
+			},
+			expected: errorOfflineUnsupported,
+		},
+		{
+			name: "invalid integrity",
+			packages: map[string]*LockedPackage{
+				"node_modules/a": {
+					Name: "a", Version: "1.0.0",
+					Resolved:  "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Integrity: "md5-invalid",
+				},
+			},
+			expected: errorInvalidLockfile,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			downloads := make(map[string]int)
+			tarballs, paths, err := buildTarballCache(&Lockfile{Packages: tt.packages}, dir, testFetch(registry, downloads))
+			if !errCmp(err, tt.expected) {
+				t.Fatalf(cmp.Diff(err, tt.expected))
+			}
+			if err != nil {
+				// Tarballs that do not verify are not written.
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != 0 {
+					t.Errorf("unexpected files in the cache: %v", entries)
+				}
+				return
+			}
+
+			expected := []ResourceDescriptor{
+				{
+					URI:         "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+					Digest:      testTarballDigests(t, tarballA),
+					Name:        "a",
+					Annotations: map[string]interface{}{"version": "1.0.0"},
+				},
+				{
+					URI:         "https://registry.npmjs.org/b/-/b-2.0.0.tgz",
+					Digest:      testTarballDigests(t, tarballB),
+					Name:        "b",
+					Annotations: map[string]interface{}{"version": "2.0.0"},
+				},
+			}
+			if diff := cmp.Diff(expected, tarballs); diff != "" {
+				t.Errorf(diff)
+			}
+
+			for i, p := range paths {
+				b, err := os.ReadFile(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(registry[tarballs[i].URI], b); diff != "" {
+					t.Errorf(diff)
+				}
+			}
+
+			// Tarballs already in the cache are reused.
+			if _, _, err := buildTarballCache(&Lockfile{Packages: tt.packages}, dir, testFetch(registry, downloads)); err != nil {
+				t.Fatal(err)
+			}
+			for url, n := range downloads {
+				if n != 1 {
+					t.Errorf("%s downloaded %d times", url, n)
+				}
+			}
+		})
+	}
+}
+
+func Test_buildTarballCache_corrupted(t *testing.T) {
+	t.Parallel()
+
+	url := "https://registry.npmjs.org/a/-/a-1.0.0.tgz"
+	l := &Lockfile{
+		Packages: map[string]*LockedPackage{
+			"node_modules/a": {Name: "a", Version: "1.0.0", Resolved: url, Integrity: integrityOf(tarballA)},
+		},
+	}
+	integrity, err := DigestSetFromString(l.Packages["node_modules/a"].Integrity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tarball of the cache that does not verify is downloaded again.
+	dir := t.TempDir()
+	fn := filepath.Join(dir, tarballFilename(integrity))
+	if err := os.WriteFile(fn, tarballB, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	downloads := make(map[string]int)
+	if _, _, err := buildTarballCache(l, dir, testFetch(map[string][]byte{url: tarballA}, downloads)); err != nil {
+		t.Fatal(err)
+	}
+	if downloads[url] != 1 {
+		t.Errorf("%s downloaded %d times", url, downloads[url])
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(tarballA, b); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func Test_InstallResult_withTarballDigests(t *testing.T) {
+	t.Parallel()
+
+	r := InstallResult{
+		Offline: true,
+		Tarballs: []ResourceDescriptor{
+			{
+				URI:    "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+				Digest: slsa.DigestSet{"sha512": "a512", "sha256": "a256"},
+			},
+		},
+	}
+	deps := []ResourceDescriptor{
+		{
+			URI:    "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+			Digest: slsa.DigestSet{"sha512": "a512"},
+			Name:   "a",
+		},
+		{
+			URI:  "git+ssh://git@github.com/org/b.git#0123456789abcdef0123456789abcdef01234567",
+			Name: "b",
+		},
+	}
+
+	expected := []ResourceDescriptor{
+		{
+			URI:    "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+			Digest: slsa.DigestSet{"sha512": "a512", "sha256": "a256"},
+			Name:   "a",
+		},
+		deps[1],
+	}
+	if diff := cmp.Diff(expected, r.withTarballDigests(deps)); diff != "" {
+		t.Errorf(diff)
+	}
+
+	// The dependencies are not modified.
+	if diff := cmp.Diff(slsa.DigestSet{"sha512": "a512"}, deps[0].Digest); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func testTarballDigests(t *testing.T, b []byte) slsa.DigestSet {
+	fn := filepath.Join(t.TempDir(), "tarball.tgz")
+	if err := os.WriteFile(fn, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	digests, err := fileDigests(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digests
+}
@@ -0,0 +1,253 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+var (
+	errorNodeVersionMismatch    = errors.New("node version mismatch")
+	errorUnsupportedNodeVersion = errors.New("node version not supported")
+)
+
+// Files that pin the version of node, as used by nvm and other
+// version managers. They contain a single version, e.g. 16 or v16.15.0,
+// or an alias, e.g. lts/*.
+var nodeVersionFiles = []string{
+	".nvmrc",
+	".node-version",
+}
+
+// SetupNodeVersionEnv is the env variable the workflow sets to the
+// version of node actions/setup-node installed.
+const SetupNodeVersionEnv = "SETUP_NODE_VERSION"
+
+// Toolchain is the node toolchain used by the build.
+type Toolchain struct {
+	NodePath    string         `json:"nodePath"`
+	NodeVersion string         `json:"nodeVersion"`
+	NodeDigest  slsa.DigestSet `json:"nodeDigest"`
+	NpmPath     string         `json:"npmPath"`
+	NpmVersion  string         `json:"npmVersion"`
+}
+
+// versionConstraint is a range of node versions required by the project.
+type versionConstraint struct {
+	// source is the file the range is defined in.
+	source string
+	r      string
+}
+
+// ResolveToolchain resolves node and npm on the PATH and verifies that
+// the version of node satisfies the constraints of the project in dir.
+// Aliases in the version files resolve to installed, the version
+// installed by actions/setup-node.
+func ResolveToolchain(pkgJson *PkgJsonConfig, dir, installed string) (*Toolchain, error) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		return nil, fmt.Errorf("exec.LookPath: %w", err)
+	}
+
+	// Record the binary rather than the link installed on the PATH.
+	node, err = filepath.EvalSymlinks(node)
+	if err != nil {
+		return nil, fmt.Errorf("filepath.EvalSymlinks: %w", err)
+	}
+
+	npm, err := exec.LookPath("npm")
+	if err != nil {
+		return nil, fmt.Errorf("exec.LookPath: %w", err)
+	}
+
+	nodeVersion, err := toolVersion(node)
+	if err != nil {
+		return nil, err
+	}
+
+	// npm is a script run by node, the same way it is for the build.
+	npmVersion, err := toolVersion(node, npm)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, err := nodeVersionConstraints(pkgJson, dir, installed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNodeVersion(nodeVersion, constraints); err != nil {
+		return nil, err
+	}
+
+	digest, err := fileSHA256(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Toolchain{
+		NodePath:    node,
+		NodeVersion: nodeVersion,
+		NodeDigest: slsa.DigestSet{
+			"sha256": digest,
+		},
+		NpmPath:    npm,
+		NpmVersion: npmVersion,
+	}, nil
+}
+
+func toolVersion(com ...string) (string, error) {
+	out, err := exec.Command(com[0], append(com[1:], "--version")...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version: %w", strings.Join(com, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// nodeVersionConstraints returns the ranges of node versions required
+// by engines.node in package.json and by the version files in dir.
+// Aliases resolve to installed.
+func nodeVersionConstraints(pkgJson *PkgJsonConfig, dir, installed string) ([]versionConstraint, error) {
+	var constraints []versionConstraint
+	if r, exists := pkgJson.Engines["node"]; exists {
+		constraints = append(constraints, versionConstraint{
+			source: "package.json engines.node",
+			r:      r,
+		})
+	}
+
+	for _, fn := range nodeVersionFiles {
+		b, err := os.ReadFile(filepath.Join(dir, fn))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile: %w", err)
+		}
+
+		v, alias, err := nodeVersionFromFile(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		if v == "" {
+			continue
+		}
+
+		source := fn
+		if alias {
+			// Aliases resolve to a different version over time:
+			// verify the version setup-node resolved the alias to.
+			if _, err := parsePartialVersion(installed); installed == "" || err != nil {
+				return nil, fmt.Errorf("%w: %s: alias %q not resolved by setup-node: %s is %q",
+					errorUnsupportedNodeVersion, fn, v, SetupNodeVersionEnv, installed)
+			}
+			source = fmt.Sprintf("%s (%s)", fn, v)
+			v = installed
+		}
+
+		constraints = append(constraints, versionConstraint{
+			source: source,
+			r:      v,
+		})
+	}
+	return constraints, nil
+}
+
+// nodeVersionFromFile returns the version in an .nvmrc or .node-version
+// file. The second value is true if the version is an alias of nvm,
+// e.g. lts/* or node.
+func nodeVersionFromFile(b []byte) (string, bool, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if _, err := parsePartialVersion(line); err == nil {
+			return line, false, nil
+		}
+		if !isNodeVersionAlias(line) {
+			return "", false, fmt.Errorf("%w: %q", errorUnsupportedNodeVersion, line)
+		}
+		return line, true, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", false, fmt.Errorf("scanner.Scan: %w", err)
+	}
+	return "", false, nil
+}
+
+// isNodeVersionAlias returns true if v is an alias actions/setup-node
+// resolves: lts/*, lts/<codename>, node, latest, current or stable.
+func isNodeVersionAlias(v string) bool {
+	switch v {
+	case "node", "latest", "current", "stable":
+		return true
+	}
+	codename := strings.TrimPrefix(v, "lts/")
+	if codename == v || codename == "" {
+		return false
+	}
+	if codename == "*" {
+		return true
+	}
+	for _, c := range codename {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func checkNodeVersion(version string, constraints []versionConstraint) error {
+	v, err := parseSemver(version)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range constraints {
+		ok, err := matchVersionRange(c.r, *v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.source, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: node %s does not satisfy %q in %s",
+				errorNodeVersionMismatch, version, c.r, c.source)
+		}
+	}
+	return nil
+}
+
+// material returns the node binary as a dependency of the build.
+func (t *Toolchain) material() ResourceDescriptor {
+	return ResourceDescriptor{
+		URI:    "file://" + t.NodePath,
+		Digest: t.NodeDigest,
+		Name:   "node",
+		Annotations: map[string]interface{}{
+			"version": t.NodeVersion,
+		},
+	}
+}
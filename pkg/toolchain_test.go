@@ -0,0 +1,157 @@
+// Copyright The GOSST team.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_nodeVersionConstraints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		engines     map[string]string
+		dir         string
+		installed   string
+		constraints []versionConstraint
+		err         error
+	}{
+		{
+			name: "no constraints",
+			dir:  "./testdata/toolchain",
+		},
+		{
+			name:    "engines",
+			engines: map[string]string{"node": ">=16", "npm": ">=8"},
+			dir:     "./testdata/toolchain",
+			constraints: []versionConstraint{
+				{source: "package.json engines.node", r: ">=16"},
+			},
+		},
+		{
+			name:    "engines and nvmrc",
+			engines: map[string]string{"node": ">=16"},
+			dir:     "./testdata/toolchain/nvmrc",
+			constraints: []versionConstraint{
+				{source: "package.json engines.node", r: ">=16"},
+				{source: ".nvmrc", r: "v16.15.0"},
+			},
+		},
+		{
+			name: "node-version with comment",
+			dir:  "./testdata/toolchain/node-version",
+			constraints: []versionConstraint{
+				{source: ".node-version", r: "16"},
+			},
+		},
+		{
+			name:      "alias",
+			dir:       "./testdata/toolchain/alias",
+			installed: "v16.15.0",
+			constraints: []versionConstraint{
+				{source: ".nvmrc (lts/gallium)", r: "v16.15.0"},
+			},
+		},
+		{
+			name: "alias not resolved",
+			dir:  "./testdata/toolchain/alias",
+			err:  errorUnsupportedNodeVersion,
+		},
+		{
+			name: "invalid version",
+			dir:  "./testdata/toolchain/invalid",
+			err:  errorUnsupportedNodeVersion,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			constraints, err := nodeVersionConstraints(&PkgJsonConfig{Engines: tt.engines}, tt.dir, tt.installed)
+			if !errCmp(err, tt.err) {
+				t.Errorf(cmp.Diff(err, tt.err))
+			}
+			if err != nil {
+				return
+			}
+			if !cmp.Equal(constraints, tt.constraints, cmp.AllowUnexported(versionConstraint{})) {
+				t.Errorf(cmp.Diff(constraints, tt.constraints, cmp.AllowUnexported(versionConstraint{})))
+			}
+		})
+	}
+}
+
+func Test_checkNodeVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		version     string
+		constraints []versionConstraint
+		expected    error
+	}{
+		{
+			name:     "no constraints",
+			version:  "v16.15.0",
+			expected: nil,
+		},
+		{
+			name:    "all satisfied",
+			version: "v16.15.0",
+			constraints: []versionConstraint{
+				{source: "package.json engines.node", r: ">=14"},
+				{source: ".nvmrc", r: "16"},
+			},
+			expected: nil,
+		},
+		{
+			name:    "mismatch",
+			version: "v18.1.0",
+			constraints: []versionConstraint{
+				{source: "package.json engines.node", r: ">=14"},
+				{source: ".nvmrc", r: "16"},
+			},
+			expected: errorNodeVersionMismatch,
+		},
+		{
+			name:    "invalid range",
+			version: "v16.15.0",
+			constraints: []versionConstraint{
+				{source: "package.json engines.node", r: "latest"},
+			},
+			expected: errorInvalidVersionRange,
+		},
+		{
+			name:     "invalid version",
+			version:  "16",
+			expected: errorInvalidVersion,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkNodeVersion(tt.version, tt.constraints)
+			if !errCmp(err, tt.expected) {
+				t.Errorf(cmp.Diff(err, tt.expected))
+			}
+		})
+	}
+}